@@ -0,0 +1,133 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+// The SQL actions of custom App Engine programs are read from PSAESTMTDEFN with their text in
+// PSSQLTEXTDEFN, and reported as PROGRAM.SECTION.STEP.ACTION. The state records of a program
+// (PSAEAPPLSTATE) are implicit in every action, so a changed state record field is found where an
+// action names the field, as in %Bind(EMPLID); a changed state record is reported once for the
+// program.
+
+// Action types with SQL
+var aeactions = map[string]string{
+	"S": "SQL",
+	"D": "DoSelect",
+	"H": "DoWhen",
+	"N": "DoWhile",
+	"U": "DoUntil",
+}
+
+// Condition selecting the App Engine programs of the projects containing custom objects
+// objecttype 33 = App Engine Program
+func aeprj(ph string) string {
+	return "ae_applid in (select objectvalue1 from psprojectitem where projectname in (" + ph + ") and objecttype = 33)"
+}
+
+func srchae(db *sql.DB, tgts []target) ([]Finding, error) {
+
+	// Only searches for the App Engine programs in the projects that contain custom objects (UPGCUST) created during the initial upgrade
+
+	x := newtgtindex(tgts, func(s string) []byte { return []byte(strings.ToUpper(s)) })
+	ids := x.ids(true)
+	byrec := tgtsbyrec(tgts)
+
+	// State records of each program; changed state records are reported once per program
+	ph, args := custbinds()
+	rows, err := db.Query("select ae_applid, ae_state_recname from psaeapplstate where "+aeprj(ph)+" order by 1, 2", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fs []Finding
+	state := map[string][]int{} // Record patterns of the state records of each program
+	for rows.Next() {
+		var appl, rec string
+		if err = rows.Scan(&appl, &rec); err != nil {
+			return nil, err
+		}
+		appl, rec = strings.TrimSpace(appl), strings.TrimSpace(rec)
+		for _, i := range byrec[rec] {
+			if tgts[i].col == "" {
+				fs = append(fs, Finding{Change: tgts[i].c, Kind: kindae, Key: appl, Location: "state record", Snippet: rec})
+			}
+		}
+		if id, ok := ids[strings.ToUpper(rec)]; ok {
+			state[appl] = append(state[appl], id)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Only the current version of each section is read, with the SQL text of the same market,
+	// platform and effective date. The text of an action is split over rows by SEQNUM.
+	typs := make([]string, 0, len(aeactions))
+	for t := range aeactions {
+		typs = append(typs, "'"+t+"'")
+	}
+	sort.Strings(typs)
+	rows, err = db.Query("select s.ae_applid, s.ae_section, s.market, s.dbtype, s.ae_step, s.ae_stmt_type, t.sqltext from psaestmtdefn s, pssqltextdefn t where t.sqlid = s.sqlid and t.sqltype = '1' and t.market = s.market and t.dbtype = s.dbtype and t.effdt = s.effdt and s.effdt = (select max(s2.effdt) from psaestmtdefn s2 where s2.ae_applid = s.ae_applid and s2.ae_section = s.ae_section and s2.market = s.market and s2.dbtype = s.dbtype and s2.effdt <= sysdate) and s.ae_stmt_type in ("+strings.Join(typs, ",")+") and s."+aeprj(ph)+" order by s.ae_applid, s.ae_section, s.market, s.dbtype, s.ae_step, s.ae_stmt_type, t.seqnum", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var appl, key, ver, loc string
+	var text []byte
+	flush := func() {
+		if key == "" {
+			return
+		}
+		found := map[int]bool{}
+		for _, tok := range sqrtokens(text, true) {
+			if id, ok := ids[string(tok)]; ok {
+				found[id] = true
+			}
+		}
+		named := map[int]bool{}
+		for id := range found {
+			named[id] = true
+		}
+		for _, id := range state[appl] {
+			found[id] = true
+		}
+		ts := x.hits(found)
+		sort.Ints(ts)
+		for _, i := range ts {
+			// A state record level change is reported for the program
+			if tgts[i].col == "" && !named[x.recpat[i]] {
+				continue
+			}
+			fs = append(fs, Finding{Change: tgts[i].c, Kind: kindae, Key: key, Location: loc, Snippet: strings.Join(strings.Fields(string(text)), " ")})
+		}
+	}
+	for rows.Next() {
+		var a1, a2, a3, a4, a5, a6 string
+		var t []byte
+		if err = rows.Scan(&a1, &a2, &a3, &a4, &a5, &a6, &t); err != nil {
+			return nil, err
+		}
+		a1, a3, a4 = strings.TrimSpace(a1), strings.TrimSpace(a3), strings.TrimSpace(a4)
+		k := a1 + "." + strings.TrimSpace(a2) + "." + strings.TrimSpace(a5) + "." + aeactions[a6]
+		if v := a3 + "|" + a4; k != key || v != ver {
+			flush()
+			appl, key, ver, text = a1, k, v, nil
+			// Section market, and platform where not generic
+			loc = "market " + a3
+			if a4 != "" {
+				loc += " platform " + a4
+			}
+		}
+		text = append(text, t...)
+	}
+	flush()
+	return fs, rows.Err()
+}
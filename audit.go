@@ -0,0 +1,119 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// The findings are kept in memory and written to UPGRADE_AUDIT and UPGRADE_TOTALS for analysis
+// in the database. In read-only mode Tekopia creates no objects in the upgraded database and the
+// findings are only reported.
+
+var readonly bool // Only issue SELECTs against the upgraded database
+
+const auditbatch = 500 // Rows inserted in UPGRADE_AUDIT per transaction
+
+// Object kinds in the order of the UPGRADE_AUDIT object columns
+var auditkinds = []string{kindsqr, kindpcode, kindsql, kindqry, kindcbl, kindae, kindpage, kindcomp, kindcqry, kindpgrid, kinddb}
+
+// Object kinds counted per change type in UPGRADE_TOTALS, with their columns and report labels
+var totalkinds = []struct{ kind, column, label string }{
+	{kindpcode, "pcode_object", "PCode"},
+	{kindsql, "sql_object", "SQL"},
+	{kindqry, "query_object", "Queries"},
+	{kindcqry, "conqry_object", "Connected Queries"},
+	{kindpgrid, "pivotgrid_object", "Pivot Grids"},
+	{kindae, "ae_object", "App Engine"},
+	{kindpage, "page_object", "Pages"},
+	{kindcomp, "component_object", "Components"},
+	{kinddb, "db_object", "Database Objects"},
+	{kindcbl, "cobol_object", "COBOL"},
+}
+
+// Totals of the change types impacting any of the objects counted in UPGRADE_TOTALS
+func impacted(fs []Finding) []total {
+	var ts []total
+	for _, t := range totals(fs) {
+		n := 0
+		for _, k := range totalkinds {
+			n += t.cnt[k.kind]
+		}
+		if n > 0 {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+// Insert the findings in UPGRADE_AUDIT, in transactions of auditbatch rows
+func logaudit(db *sql.DB, fs []Finding) error {
+	if readonly {
+		return nil
+	}
+
+	for len(fs) > 0 {
+		n := len(fs)
+		if n > auditbatch {
+			n = auditbatch
+		}
+		if err := insaudit(db, fs[:n]); err != nil {
+			return err
+		}
+		fs = fs[n:]
+	}
+	return nil
+}
+
+// Insert findings in one transaction
+func insaudit(db *sql.DB, fs []Finding) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("insert into upgrade_audit(change_type, sqr_object, pcode_object, sql_object, query_object, cobol_object, ae_object, page_object, component_object, conqry_object, pivotgrid_object, db_object) values (:cfrom, :sqrobj, :pcodeobj, :sqlobj, :queryobj, :cobolobj, :aeobj, :pageobj, :compobj, :cqryobj, :pgridobj, :dbobj)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, f := range fs {
+		args := []interface{}{f.Change.Category}
+		for _, k := range auditkinds {
+			args = append(args, sql.NullString{String: f.Key, Valid: f.Kind == k})
+		}
+		if _, err = stmt.Exec(args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Insert the impacted object counts per change type in UPGRADE_TOTALS
+func logtotals(db *sql.DB, ts []total) error {
+	if readonly {
+		return nil
+	}
+
+	cols, binds := []string{"change_type"}, []string{":cfrom"}
+	for i, k := range totalkinds {
+		cols = append(cols, k.column)
+		binds = append(binds, ":cnt"+strconv.Itoa(i))
+	}
+	for _, t := range ts {
+		args := []interface{}{t.chgtype}
+		for _, k := range totalkinds {
+			args = append(args, t.cnt[k.kind])
+		}
+		_, err := db.Exec("insert into upgrade_totals("+strings.Join(cols, ", ")+") values ("+strings.Join(binds, ", ")+")", args...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
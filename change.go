@@ -0,0 +1,200 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// The change detectors produce Changes. Scanners search custom objects for the changes and
+// produce Findings, which the reporters count, print and write out.
+
+// Change categories, stored as the change type in UPGRADE_AUDIT
+const (
+	catobsrec = "Get-Obsolete-Records"
+	catobsfld = "Get-Obsolete-Fields"
+	catnewrec = "Get-New-Records"
+	catnewfld = "Get-New-Fields"
+	catrecvw  = "Get-Records-Now-Views"
+	catvwrec  = "Get-Views-Now-Records"
+	cattrcfld = "Get-Field-Length-Changes"
+	catrenrec = "Get-Renamed-Records"
+	catrenfld = "Get-Renamed-Objects"
+	catrentbl = "Get-Renamed-Tables"
+)
+
+// Kinds of custom objects
+const (
+	kindsqr   = "SQR"
+	kindpcode = "PCode"
+	kindsql   = "SQL"
+	kindqry   = "Query"
+	kindcbl   = "COBOL"
+	kindae    = "App Engine"
+	kindpage  = "Page"
+	kindcomp  = "Component"
+	kindcqry  = "Connected Query"
+	kindpgrid = "Pivot Grid"
+	kinddb    = "Database Object"
+)
+
+// Structure change between the old and new release
+type Change struct {
+	Category         string // Change category, such as Get-Obsolete-Records
+	Record           string // Record name, empty for field definition changes; the old name for renamed records
+	Field            string // Field name, empty for record level changes; the old name for renamed fields
+	OldName, NewName string // Names before and after a rename
+	Table            string // Database table of the record, in the old release where it exists there; "" for field definition changes
+	OldType, NewType string // PSRECDEFN.RECTYPE in the old and new release, "" when absent
+	OldLen, NewLen   int    // PSDBFIELD.LENGTH in the old and new release
+}
+
+// Custom object referencing a change
+type Finding struct {
+	Change   *Change
+	Kind     string // Object kind, such as SQR or PCode
+	Key      string // Object key, such as the SQR path or the SQL id and type
+	Location string // Location in the object, such as the line number
+	Snippet  string // Text referencing the change
+}
+
+// Record or field name of a change
+func (c *Change) String() string {
+	switch {
+	case c.Field == "":
+		return c.Record
+	case c.Record == "":
+		return c.Field
+	}
+	return c.Record + "." + c.Field
+}
+
+// Report heading of a change category, "" for categories not searched for
+func catname(cat string) string {
+	switch cat {
+	case catnewfld:
+		return "New fields added to existing tables"
+	case catobsfld:
+		return "Obsolete fields"
+	case catobsrec:
+		return "Obsolete records"
+	case catrecvw:
+		return "Records now Views"
+	case catvwrec:
+		return "Views now Records"
+	case catrenrec:
+		return "Renamed Records"
+	case catrenfld:
+		return "Renamed Objects"
+	case catrentbl:
+		return "Records with a new table name"
+	}
+	return ""
+}
+
+// Database table of the record of a change, from PSRECDEFN.SQLTABLENAME or PS_ and the record name
+func (c *Change) table() string {
+	if c.Table != "" {
+		return c.Table
+	}
+	return "PS_" + c.Record
+}
+
+// Table and field searched for in SQRs; the field is empty for table level searches
+func (c *Change) sqrtarget() (tbl, fld string, ok bool) {
+	switch c.Category {
+	case catobsfld, catrenfld:
+		return c.table(), c.Field, true
+	case catobsrec, catnewfld, catrecvw, catvwrec, catrenrec, catrentbl:
+		return c.table(), "", true
+	}
+	return "", "", false
+}
+
+// Record and field searched for in online objects; the field is empty for record level searches.
+// A record with a new table name is searched for by its old table name only, as in SQL text.
+func (c *Change) onltarget() (rec, fld string, ok bool) {
+	switch c.Category {
+	case catrentbl:
+		return c.table(), "", true
+	case catobsfld, catnewfld, catrenfld:
+		return c.Record, c.Field, true
+	case catobsrec, catrecvw, catvwrec, catrenrec:
+		return c.Record, "", true
+	}
+	return "", "", false
+}
+
+// Log line of a finding
+func (f Finding) String() string {
+	s := "            Found in " + f.Kind + ": " + f.Key
+	if f.Location != "" {
+		s += " => " + f.Location
+	}
+	if f.Snippet != "" {
+		s += " - " + f.Snippet
+	}
+	return s
+}
+
+// Distinct impacted objects of one change category by object kind
+type total struct {
+	chgtype string
+	cnt     map[string]int
+}
+
+// Count the distinct impacted objects per change category, in order of first appearance
+func totals(fs []Finding) []total {
+	var ts []total
+	idx := map[string]int{}
+	seen := map[[3]string]bool{}
+	for _, f := range fs {
+		i, ok := idx[f.Change.Category]
+		if !ok {
+			i = len(ts)
+			idx[f.Change.Category] = i
+			ts = append(ts, total{f.Change.Category, map[string]int{}})
+		}
+		k := [3]string{f.Change.Category, f.Kind, f.Key}
+		if !seen[k] {
+			seen[k] = true
+			ts[i].cnt[f.Kind]++
+		}
+	}
+	return ts
+}
+
+// Count the distinct objects of a kind, optionally only those whose key contains sub
+func count(fs []Finding, kind, sub string) int {
+	seen := map[string]bool{}
+	for _, f := range fs {
+		if f.Kind == kind && strings.Contains(f.Key, sub) {
+			seen[f.Key] = true
+		}
+	}
+	return len(seen)
+}
+
+// Findings of one kind
+func bykind(fs []Finding, kind string) []Finding {
+	var r []Finding
+	for _, f := range fs {
+		if f.Kind == kind {
+			r = append(r, f)
+		}
+	}
+	return r
+}
+
+// Sort findings in order of the changes, keeping the scan order for each change
+func sortfindings(chgs []Change, fs []Finding) {
+	pos := map[*Change]int{}
+	for i := range chgs {
+		pos[&chgs[i]] = i
+	}
+	sort.SliceStable(fs, func(i, j int) bool {
+		return pos[fs[i].Change] < pos[fs[j].Change]
+	})
+}
@@ -0,0 +1,156 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Report commands, one per mode
+var commands = []struct {
+	name string
+	mode int
+	desc string
+}{
+	{"changes", 1, "List structure changes only"},
+	{"audit-sqr", 2, "Run audit only for SQRs"},
+	{"audit-online", 3, "Run audit only for online objects"},
+	{"full", 4, "Run full report"},
+}
+
+// parseargs reads the command and flags. Usage: tekopia [command] [flags] [dsn]
+// Without a command the report option is prompted for, which is only allowed when stdin is a terminal.
+func parseargs(args []string) error {
+	var profile string
+	fs := flag.NewFlagSet(rid, flag.ContinueOnError)
+	fs.StringVar(&profile, "profile", os.Getenv(envname("profile")), "run profile file with settings for this engagement")
+	fs.StringVar(&dsn, "dsn", dsn, "connect string user/name@host:port/sid (default $GO_OCI8_CONNECT_STRING)")
+	fs.StringVar(&olddsn, "olddsn", olddsn, "connect string of the old release demo, used instead of a database link")
+	fs.StringVar(&dblink, "dblink", dblink, "database link from the upgraded database to the old release demo")
+	fs.Var((*listflag)(&sqrdirs), "sqrdir", "comma-separated directories where custom SQRs reside")
+	fs.StringVar(&upgrade, "compare", upgrade, "database compare project containing records")
+	fs.BoolVar(&native, "native", native, "compare PSRECDEFN and PSRECFIELD of both releases instead of reading the compare project")
+	fs.Var((*listflag)(&upgcust), "custom", "comma-separated projects containing custom objects")
+	fs.Var((*listflag)(&cbldirs), "cbldir", "comma-separated directories where custom COBOL programs and .dms stored statements reside")
+	fs.Var((*listflag)(&sqrinc), "sqrinc", "comma-separated directories searched for #include files, as with the SQR -I option")
+	fs.Var((*listflag)(&sqrpats), "patterns", "comma-separated file name or path patterns of SQRs to search")
+	fs.Var((*listflag)(&sqrexcl), "exclude", "comma-separated file and directory name or path patterns to skip; a trailing / matches directories only")
+	fs.BoolVar(&followlinks, "follow", followlinks, "follow symbolic links in the SQR directories")
+	fs.Var((*listflag)(&scannames), "scanners", "comma-separated scanners to run: "+scannerlist()+" (default by command)")
+	fs.IntVar(&workers, "workers", workers, "number of goroutines scanning SQRs")
+	fs.BoolVar(&readonly, "readonly", readonly, "only select from the upgraded database; analyze in memory")
+	fs.StringVar(&outdir, "out", outdir, "directory where tekopia.log is written")
+	fs.Var((*listflag)(&formats), "formats", "comma-separated output formats: log, csv")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags] [dsn]\n\nCommands:\n", strings.ToLower(rid))
+		for _, c := range commands {
+			fmt.Fprintf(os.Stderr, "  %-14s %d. %s\n", c.name, c.mode, c.desc)
+		}
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	// Flags may appear before or after positional arguments
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(pos) > 0 && !strings.ContainsAny(pos[0], "/@") {
+		if mode = cmdmode(pos[0]); mode == 0 {
+			fs.Usage()
+			return fmt.Errorf("unknown command: %s", pos[0])
+		}
+		pos = pos[1:]
+	}
+
+	// A trailing argument is the connect string, as in earlier releases
+	switch len(pos) {
+	case 0:
+	case 1:
+		if dsn == "" {
+			fs.Set("dsn", pos[0])
+		}
+	default:
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(pos[1:], " "))
+	}
+
+	if err := loadsettings(fs, profile); err != nil {
+		return err
+	}
+	for _, f := range formats {
+		if f != "log" && f != "csv" {
+			return fmt.Errorf("unknown output format: %s", f)
+		}
+	}
+
+	if err := chkpatterns(append(append([]string(nil), sqrpats...), sqrexcl...)); err != nil {
+		return err
+	}
+
+	if err := chkscanners(); err != nil {
+		return err
+	}
+
+	if workers < 1 {
+		return fmt.Errorf("workers must be at least 1")
+	}
+
+	if len(upgcust) == 0 {
+		return fmt.Errorf("at least one custom objects project is required")
+	}
+
+	if mode == 0 && !interactive() {
+		fs.Usage()
+		return fmt.Errorf("no command given and stdin is not a terminal")
+	}
+	return nil
+}
+
+// Mode for a command name, 0 if unknown
+func cmdmode(name string) int {
+	for _, c := range commands {
+		if name == c.name {
+			return c.mode
+		}
+	}
+	return 0
+}
+
+// listflag is a comma-separated list flag
+type listflag []string
+
+func (l *listflag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listflag) Set(v string) error {
+	*l = nil
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// interactive reports whether stdin is a terminal
+func interactive() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
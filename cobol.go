@@ -0,0 +1,261 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Custom COBOL programs are read from the COBOL directories, with the stored statements of .dms
+// files in the same directories and those of the programs found in PS_SQLSTMT_TBL. Changed tables
+// and fields are searched for as in SQRs: a field change needs its table in the same stored
+// statement or COBOL paragraph. Findings are keyed by program.
+
+var cbldirs []string // Directories where custom COBOL programs and .dms stored statements reside
+
+// Scanner of the custom COBOL programs and their stored statements
+type cblscanner struct{}
+
+func (cblscanner) Name() string {
+	return "cobol"
+}
+
+func (cblscanner) Scan(db *sql.DB, chgs []Change) ([]Finding, error) {
+	tgts := sqrtargets(chgs)
+	if len(tgts) == 0 {
+		return nil, nil
+	}
+
+	x := newtgtindex(tgts, func(s string) []byte { return bytes.ToUpper([]byte(s)) })
+	ids := x.ids(false)
+	srch := func(toks [][]byte) []int {
+		found := map[int]bool{}
+		for _, tok := range toks {
+			if id, ok := ids[string(tok)]; ok {
+				found[id] = true
+			}
+		}
+		ts := x.hits(found)
+		sort.Ints(ts)
+		return ts
+	}
+
+	var fs []Finding
+	pgms := map[string]bool{}
+	err := walkfiles(cbldirs, []string{"*.cbl", "*.dms"}, func(fp string, fi os.FileInfo) error {
+		var f []Finding
+		var err error
+		if strings.EqualFold(filepath.Ext(fp), ".dms") {
+			f, err = srchdms(fp, tgts, srch)
+		} else {
+			pgm := strings.ToUpper(strings.TrimSuffix(fi.Name(), filepath.Ext(fi.Name())))
+			pgms[pgm] = true
+			f, err = srchcbl(fp, pgm, tgts, srch)
+		}
+		fs = append(fs, f...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := srchstmts(db, pgms, tgts, srch)
+	if err != nil {
+		return nil, err
+	}
+	fs = append(fs, f...)
+	return fs, prtfindings("Custom COBOL referencing the changes", chgs, fs)
+}
+
+// Scan a fixed format COBOL program paragraph by paragraph. Columns 1-6 and 73-80 are ignored,
+// and lines with * or / in column 7 are comments.
+func srchcbl(fp, pgm string, tgts []target, srch func([][]byte) []int) ([]Finding, error) {
+	file, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var fs []Finding
+	var toks [][]byte
+	var para string
+	var first int // First line of the paragraph
+	flush := func() {
+		for _, i := range srch(toks) {
+			loc := "line: " + strconv.Itoa(first)
+			if para != "" {
+				loc = "paragraph " + para + " " + loc
+			}
+			fs = append(fs, Finding{Change: tgts[i].c, Kind: kindcbl, Key: pgm, Location: loc})
+		}
+		toks = nil
+	}
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	procdiv := false
+	for scanner.Scan() {
+		lineNumber += 1
+		line := scanner.Bytes()
+		if len(line) > 72 {
+			line = line[:72]
+		}
+		if len(line) <= 6 || line[6] == '*' || line[6] == '/' {
+			continue
+		}
+		line = line[6:]
+		t := cbltokens(line[1:])
+		if len(t) == 0 {
+			continue
+		}
+
+		// Each paragraph of the procedure division is a scope; other lines stand alone
+		switch {
+		case len(t) >= 2 && string(t[0]) == "PROCEDURE" && string(t[1]) == "DIVISION":
+			flush()
+			procdiv, para = true, ""
+			continue
+		case procdiv && len(line) > 1 && line[1] != ' ' && len(t) == 1 && bytes.HasSuffix(bytes.TrimSpace(line), []byte(".")):
+			flush()
+			para = string(t[0])
+			continue
+		}
+		if len(toks) == 0 {
+			first = lineNumber
+		}
+		toks = append(toks, t...)
+		if !procdiv {
+			flush()
+		}
+	}
+	flush()
+	return fs, scanner.Err()
+}
+
+// Upper-cased COBOL words of a line, including the words in literals, where stored statement
+// names and table names appear
+func cbltokens(line []byte) [][]byte {
+	var toks [][]byte
+	for i := 0; i < len(line); {
+		if !identchar(line[i], false) || line[i] == '-' {
+			i++
+			continue
+		}
+		j := i
+		for i++; i < len(line) && identchar(line[i], false); i++ {
+		}
+		toks = append(toks, bytes.ToUpper(bytes.TrimRight(line[j:i], "-")))
+	}
+	return toks
+}
+
+// Scan the stored statements of a Data Mover script: STORE name, the SQL, then a line ending in ;
+func srchdms(fp string, tgts []target, srch func([][]byte) []int) ([]Finding, error) {
+	file, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var fs []Finding
+	var stmt string
+	var text []byte
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if stmt == "" {
+			if f := strings.Fields(string(line)); len(f) == 2 && strings.EqualFold(f[0], "STORE") {
+				stmt, text = strings.ToUpper(f[1]), nil
+			}
+			continue
+		}
+		end := bytes.HasSuffix(line, []byte(";"))
+		text = append(append(text, bytes.TrimSuffix(line, []byte(";"))...), '\n')
+		if end {
+			fs = append(fs, stmtfindings(stmt, "in "+fp, text, tgts, srch)...)
+			stmt = ""
+		}
+	}
+	return fs, scanner.Err()
+}
+
+// Scan the stored statements of the custom programs in PS_SQLSTMT_TBL
+func srchstmts(db *sql.DB, pgms map[string]bool, tgts []target, srch func([][]byte) []int) ([]Finding, error) {
+	names := make([]string, 0, len(pgms))
+	for p := range pgms {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+
+	// Oracle allows at most 1000 expressions in a list
+	var fs []Finding
+	for len(names) > 0 {
+		n := len(names)
+		if n > 1000 {
+			n = 1000
+		}
+		ph := make([]string, n)
+		args := make([]interface{}, n)
+		for i, p := range names[:n] {
+			ph[i] = ":pgm" + strconv.Itoa(i)
+			args[i] = p
+		}
+		names = names[n:]
+
+		f, err := srchstmtrows(db, "select pgm_name, stmt_type, stmt_name, stmt_text from ps_sqlstmt_tbl where pgm_name in ("+strings.Join(ph, ", ")+") order by 1, 2, 3", args, tgts, srch)
+		if err != nil {
+			return nil, err
+		}
+		fs = append(fs, f...)
+	}
+	return fs, nil
+}
+
+func srchstmtrows(db *sql.DB, query string, args []interface{}, tgts []target, srch func([][]byte) []int) ([]Finding, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fs []Finding
+	for rows.Next() {
+		var pgm, typ, name string
+		var text []byte
+		if err = rows.Scan(&pgm, &typ, &name, &text); err != nil {
+			return nil, err
+		}
+		fs = append(fs, stmtfindings(strings.TrimSpace(pgm)+"_"+strings.TrimSpace(typ)+"_"+strings.TrimSpace(name), "", text, tgts, srch)...)
+	}
+	return fs, rows.Err()
+}
+
+// Findings of one stored statement, named PROGRAM_TYPE_NAME
+func stmtfindings(stmt, where string, text []byte, tgts []target, srch func([][]byte) []int) []Finding {
+	pgm := stmt
+	if i := strings.IndexByte(stmt, '_'); i > 0 {
+		pgm = stmt[:i]
+	}
+	loc := "statement " + stmt
+	if where != "" {
+		loc += " " + where
+	}
+
+	var toks [][]byte
+	for _, l := range bytes.Split(text, []byte("\n")) {
+		toks = append(toks, sqrtokens(l, true)...)
+	}
+	var fs []Finding
+	for _, i := range srch(toks) {
+		fs = append(fs, Finding{Change: tgts[i].c, Kind: kindcbl, Key: pgm, Location: loc, Snippet: strings.Join(strings.Fields(string(text)), " ")})
+	}
+	return fs
+}
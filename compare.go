@@ -0,0 +1,230 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+// Record and field changes come from the Application Designer compare project, or with -native
+// from comparing PSRECDEFN and PSRECFIELD of both releases, which makes the compare step optional.
+// Both give rows of record, [field,] record type for the change detectors.
+
+var native bool // Compare records natively instead of reading the compare project
+
+// Run a compare project query returning string columns
+func projrows(db *sql.DB, query string) ([][]string, error) {
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(upgrade)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var recs [][]string
+	for rows.Next() {
+		r := make([]string, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range r {
+			dest[i] = &r[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		recs = append(recs, r)
+	}
+	return recs, rows.Err()
+}
+
+// Obsolete records with their old release record type
+func obsrecs(db *sql.DB) ([][]string, error) {
+	n, o, err := releases(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var recs [][]string
+	if native {
+		for rec, t := range o.rectype {
+			if _, ok := n.rectype[rec]; !ok {
+				recs = append(recs, []string{rec, t})
+			}
+		}
+		sortrows(recs)
+		return recs, nil
+	}
+
+	// upgradeaction 3 = CopyProp
+	// sourcestatus 1 = Absent
+	items, err := projrows(db, "select aa.objectvalue1 from psprojectitem aa where aa.objecttype = 0 and aa.objectid1 = 1 and aa.sourcestatus ^= aa.targetstatus and aa.upgradeaction ^= 3 and aa.sourcestatus = 1 and aa.objectvalue2 = ' ' and aa.projectname = :upgrade order by 1")
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range items {
+		rec := r[0]
+		if len(rec) > 15 {
+			rec = rec[:15]
+		}
+		if t, ok := o.rectype[rec]; ok {
+			recs = append(recs, []string{r[0], t})
+		}
+	}
+	return recs, nil
+}
+
+// Fields removed from records that exist in both releases, with the new release record type
+func obsflds(db *sql.DB) ([][]string, error) {
+	if !native {
+		return projrows(db, "select o.objectvalue1, o.objectvalue2, kk.rectype from psprojectitem o, psrecdefn kk where o.objecttype = 0 and o.objectid1 = 1 and sourcestatus ^= targetstatus and upgradeaction ^= 3 and sourcestatus = 1 and o.objectvalue2 ^= ' ' and o.objectvalue1 = kk.recname and o.projectname = :upgrade order by 1")
+	}
+	n, o, err := recfields(db)
+	if err != nil {
+		return nil, err
+	}
+	return flddiff(o, n, n, nil), nil
+}
+
+// New records, not counting renamed records, with their record type
+func newrecs(db *sql.DB) ([][]string, error) {
+	if !native {
+		return projrows(db, "select n.objectvalue1, p.rectype from psprojectitem n, psrecdefn p where n.objecttype = 0 and n.objectid1 = 1 and n.sourcestatus ^= n.targetstatus and upgradeaction ^= 3 and n.sourcestatus not in (4,5) and n.targetstatus = 1 and n.objectvalue2 = ' ' and substr(n.objectvalue1,1,15) = p.recname and n.objectvalue1 not in (select newname from psobjchng where enttype = 'R') and n.projectname = :upgrade order by 1")
+	}
+	n, o, err := releases(db)
+	if err != nil {
+		return nil, err
+	}
+	ren, err := renamed(db, "select newname, ' ' from psobjchng where enttype = 'R'")
+	if err != nil {
+		return nil, err
+	}
+
+	var recs [][]string
+	for rec, t := range n.rectype {
+		if _, ok := o.rectype[rec]; !ok && !ren[rec] {
+			recs = append(recs, []string{rec, t})
+		}
+	}
+	sortrows(recs)
+	return recs, nil
+}
+
+// Fields added to records that exist in both releases, not counting renamed fields, with the record type
+func newflds(db *sql.DB) ([][]string, error) {
+	if !native {
+		return projrows(db, "select z.objectvalue1, z.objectvalue2, pp.rectype from psprojectitem z, psrecdefn pp where z.objecttype = 0 and z.objectid1 = 1 and z.sourcestatus ^= z.targetstatus and z.upgradeaction ^= 3 and z.targetstatus = 1 and z.objectvalue2 ^= ' ' and substr(z.objectvalue1,1,15) = pp.recname and z.objectvalue2 not in (select qq.newname from psobjchng qq where qq.enttype = '3' and z.objectvalue1 = qq.oldname2) and z.projectname = :upgrade order by 1,2")
+	}
+	n, o, err := recfields(db)
+	if err != nil {
+		return nil, err
+	}
+	ren, err := renamed(db, "select oldname, newname from psobjchng where enttype = '3'")
+	if err != nil {
+		return nil, err
+	}
+	return flddiff(n, o, n, ren), nil
+}
+
+// Record fields present in a but not in b, for records in both releases, skipping the record.field keys in skip
+func flddiff(a, b, types *release, skip map[string]bool) [][]string {
+	var flds [][]string
+	for rec, fa := range a.recfield {
+		fb, ok := b.recfield[rec]
+		if !ok {
+			continue
+		}
+		for fld := range fa {
+			if !fb[fld] && !skip[rec+"."+fld] {
+				flds = append(flds, []string{rec, fld, types.rectype[rec]})
+			}
+		}
+	}
+	sortrows(flds)
+	return flds
+}
+
+// Renamed objects from PSOBJCHNG as a set of name or name.name keys
+func renamed(db *sql.DB, query string) (map[string]bool, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ren := map[string]bool{}
+	for rows.Next() {
+		var k1, k2 string
+		if err = rows.Scan(&k1, &k2); err != nil {
+			return nil, err
+		}
+		if k2 = strings.TrimSpace(k2); k2 != "" {
+			k1 += "." + k2
+		}
+		ren[k1] = true
+	}
+	return ren, rows.Err()
+}
+
+// Load the record fields of both releases
+func recfields(db *sql.DB) (*release, *release, error) {
+	n, o, err := releases(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n.recfield != nil {
+		return n, o, nil
+	}
+	if n.recfield, err = loadrecfld(db, "psrecfield"); err != nil {
+		return nil, nil, err
+	}
+	odb, otbl := oldsrc(db, "psrecfield")
+	if o.recfield, err = loadrecfld(odb, otbl); err != nil {
+		n.recfield = nil
+		return nil, nil, err
+	}
+	return n, o, nil
+}
+
+func loadrecfld(db *sql.DB, tbl string) (map[string]map[string]bool, error) {
+	rows, err := db.Query("select recname, fieldname from " + tbl)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recfld := map[string]map[string]bool{}
+	for rows.Next() {
+		var o1, o2 string
+		if err = rows.Scan(&o1, &o2); err != nil {
+			return nil, err
+		}
+		if recfld[o1] == nil {
+			recfld[o1] = map[string]bool{}
+		}
+		recfld[o1][o2] = true
+	}
+	return recfld, rows.Err()
+}
+
+// Sort rows by their columns in order
+func sortrows(recs [][]string) {
+	sort.Slice(recs, func(i, j int) bool {
+		for k := range recs[i] {
+			if recs[i][k] != recs[j][k] {
+				return recs[i][k] < recs[j][k]
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,237 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A run profile is a TOML file of flat key = value settings, one key per flag, for example
+//
+//	# HCM 9.2 upgrade
+//	command = "full"
+//	dblink  = "HRDMO91"
+//	custom  = ["UPGCUST", "RETROFIT1"]
+//	sqrdir  = "/psft/custom/sqr"
+//	exclude = ["*.bak", "archive"]
+//
+// Values are strings, integers, booleans or arrays of those. Settings are applied in order of
+// precedence: command line flags, TEKOPIA_<KEY> environment variables, the profile, built-in defaults.
+// The connect string holds a password, so it is given in TEKOPIA_DSN or GO_OCI8_CONNECT_STRING
+// rather than in a profile that is checked in.
+
+// Apply the profile and environment settings not given on the command line
+func loadsettings(fs *flag.FlagSet, profile string) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	// A command on the command line or in TEKOPIA_COMMAND overrides the profile's
+	if v := os.Getenv(envname("command")); v != "" && mode == 0 {
+		if mode = cmdmode(v); mode == 0 {
+			return fmt.Errorf("%s: unknown command: %s", envname("command"), v)
+		}
+	}
+	cmdset := mode != 0
+
+	if profile != "" {
+		kv, err := readprofile(profile)
+		if err != nil {
+			return err
+		}
+		for _, p := range kv {
+			if p.key == "command" {
+				if cmdmode(p.val) == 0 {
+					return fmt.Errorf("%s:%d: unknown command: %s", profile, p.line, p.val)
+				}
+				if !cmdset {
+					mode = cmdmode(p.val)
+				}
+				continue
+			}
+			if fs.Lookup(p.key) == nil || p.key == "profile" {
+				return fmt.Errorf("%s:%d: unknown setting: %s", profile, p.line, p.key)
+			}
+			if set[p.key] {
+				continue
+			}
+			if err = fs.Set(p.key, p.val); err != nil {
+				return fmt.Errorf("%s:%d: %s: %v", profile, p.line, p.key, err)
+			}
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		v, ok := os.LookupEnv(envname(f.Name))
+		if !ok || set[f.Name] || f.Name == "profile" || err != nil {
+			return
+		}
+		if e := fs.Set(f.Name, v); e != nil {
+			err = fmt.Errorf("%s: %v", envname(f.Name), e)
+		}
+	})
+	return err
+}
+
+// Environment variable overriding a setting
+func envname(key string) string {
+	return "TEKOPIA_" + strings.ToUpper(strings.Replace(key, "-", "_", -1))
+}
+
+type profval struct {
+	key, val string
+	line     int
+}
+
+// Read the key = value pairs of a profile. Arrays are returned comma-separated.
+func readprofile(path string) ([]profval, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var kv []profval
+	var pending string // Array continued on the following lines
+	start := 0
+	lineNumber := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNumber += 1
+		line := strings.TrimSpace(stripcomment(scanner.Text()))
+		if pending != "" {
+			pending += " " + line
+			if !strings.HasSuffix(line, "]") {
+				continue
+			}
+			line, pending = pending, ""
+		} else {
+			start = lineNumber
+		}
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("%s:%d: tables are not supported in profiles", path, lineNumber)
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNumber)
+		}
+		key := strings.TrimSpace(line[:i])
+		raw := strings.TrimSpace(line[i+1:])
+		if strings.HasPrefix(raw, "[") && !strings.HasSuffix(raw, "]") {
+			pending = line
+			continue
+		}
+		val, err := tomlval(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %v", path, start, key, err)
+		}
+		kv = append(kv, profval{strings.Trim(key, `"`), val, start})
+	}
+	if pending != "" {
+		return nil, fmt.Errorf("%s:%d: unterminated array", path, start)
+	}
+	return kv, scanner.Err()
+}
+
+// Parse a TOML string, integer, boolean or array value
+func tomlval(raw string) (string, error) {
+	if strings.HasPrefix(raw, "[") {
+		var vals []string
+		body := strings.TrimSpace(raw[1 : len(raw)-1])
+		for body != "" {
+			v, rest, err := tomlscalar(body)
+			if err != nil {
+				return "", err
+			}
+			vals = append(vals, v)
+			rest = strings.TrimSpace(rest)
+			if rest != "" && rest[0] != ',' {
+				return "", fmt.Errorf("expected , in array")
+			}
+			body = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+		}
+		return strings.Join(vals, ","), nil
+	}
+	v, rest, err := tomlscalar(raw)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return "", fmt.Errorf("unexpected text after value: %s", rest)
+	}
+	return v, nil
+}
+
+// Parse one scalar value, returning the value and the remaining text
+func tomlscalar(s string) (string, string, error) {
+	if s == "" {
+		return "", "", fmt.Errorf("missing value")
+	}
+	switch s[0] {
+	case '\'':
+		i := strings.IndexByte(s[1:], '\'')
+		if i < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : i+1], s[i+2:], nil
+	case '"':
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '"':
+				return b.String(), s[i+1:], nil
+			case '\\':
+				i++
+				if i == len(s) {
+					return "", "", fmt.Errorf("unterminated string")
+				}
+				switch s[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(s[i])
+				}
+			default:
+				b.WriteByte(s[i])
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string")
+	}
+	i := strings.IndexAny(s, ", ")
+	if i == 0 {
+		return "", "", fmt.Errorf("missing value")
+	}
+	if i < 0 {
+		i = len(s)
+	}
+	return s[:i], s[i:], nil
+}
+
+// Remove a # comment that is not inside a quoted string
+func stripcomment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0 && c == '\\' && quote == '"':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,101 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Write a profile to a temporary file
+func writeprofile(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "profile.toml")
+	if err := os.WriteFile(path, []byte(text), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadsettingsPrecedence(t *testing.T) {
+	const profile = "command = \"full\"\ndblink = \"PROFILE\"\n"
+	tests := []struct {
+		name       string
+		args       []string // Command line flags
+		cmd        string   // Command on the command line
+		env        map[string]string
+		wantmode   int
+		wantdblink string
+	}{
+		{"profile", nil, "", nil, 4, "PROFILE"},
+		{"env over profile", nil, "", map[string]string{"TEKOPIA_COMMAND": "changes", "TEKOPIA_DBLINK": "ENV"}, 1, "ENV"},
+		{"flags over env", []string{"-dblink", "FLAG"}, "audit-sqr", map[string]string{"TEKOPIA_COMMAND": "changes", "TEKOPIA_DBLINK": "ENV"}, 2, "FLAG"},
+	}
+	path := writeprofile(t, profile)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			var link string
+			fs := flag.NewFlagSet("tekopia", flag.ContinueOnError)
+			fs.StringVar(&link, "dblink", "DEFAULT", "")
+			fs.String("profile", "", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			defer func(m int) { mode = m }(mode)
+			mode = cmdmode(tt.cmd)
+
+			if err := loadsettings(fs, path); err != nil {
+				t.Fatal(err)
+			}
+			if mode != tt.wantmode || link != tt.wantdblink {
+				t.Errorf("mode %d, dblink %s; want %d, %s", mode, link, tt.wantmode, tt.wantdblink)
+			}
+		})
+	}
+}
+
+func TestReadprofile(t *testing.T) {
+	tests := []struct {
+		name, text string
+		want       []profval
+		wanterr    string // Suffix of the error
+	}{
+		{"scalars", "# comment\ndsn = \"a@b\" # trailing\nworkers = 4\nfollow = true\nout = 'rep#1'\n",
+			[]profval{{"dsn", "a@b", 2}, {"workers", "4", 3}, {"follow", "true", 4}, {"out", "rep#1", 5}}, ""},
+		{"escapes", `dblink = "a\"b\\c"` + "\n", []profval{{"dblink", `a"b\c`, 1}}, ""},
+		{"array", "custom = [\"UPGCUST\", 'RETRO']\n", []profval{{"custom", "UPGCUST,RETRO", 1}}, ""},
+		{"multiline array", "\nexclude = [\n  \"*.bak\",\n  \"archive/\",\n]\n", []profval{{"exclude", "*.bak,archive/", 2}}, ""},
+		{"missing value", "dsn =\n", nil, ":1: dsn: missing value"},
+		{"missing array value", "custom = [\"A\", ,]\n", nil, ":1: custom: missing value"},
+		{"table", "[db]\n", nil, ":1: tables are not supported in profiles"},
+		{"no equals", "dsn\n", nil, ":1: expected key = value"},
+		{"unterminated string", "dsn = \"abc\n", nil, ":1: dsn: unterminated string"},
+		{"unterminated array", "custom = [\"A\",\n", nil, ":1: unterminated array"},
+		{"trailing text", "dsn = \"a\" b\n", nil, ":1: dsn: unexpected text after value:  b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kv, err := readprofile(writeprofile(t, tt.text))
+			if tt.wanterr != "" {
+				if err == nil || !strings.HasSuffix(err.Error(), tt.wanterr) {
+					t.Errorf("error %v, want %s", err, tt.wanterr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(kv, tt.want) {
+				t.Errorf("got %v, want %v", kv, tt.want)
+			}
+		})
+	}
+}
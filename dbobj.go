@@ -0,0 +1,224 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Database objects created outside PeopleTools in the PeopleSoft schema are searched in their
+// source: triggers and PL/SQL units in ALL_SOURCE, and views and materialized views that are not
+// PeopleTools records in ALL_VIEWS and ALL_MVIEWS. SQL in the database names tables, so records
+// are matched by their table name only, and a field change needs its table in the same object.
+// Objects that ALL_DEPENDENCIES records as depending on a changed table are reported even where
+// the text does not name it, and a dependency on a synonym is resolved through ALL_SYNONYMS to
+// its table. The ALL_ views need no catalog privileges for the objects of the current schema.
+
+// Schema of the PeopleSoft tables
+const curschema = "sys_context('userenv', 'current_schema')"
+
+// Types of the PL/SQL units searched
+const dbsrctypes = "'TRIGGER', 'PACKAGE', 'PACKAGE BODY', 'PROCEDURE', 'FUNCTION', 'TYPE BODY'"
+
+// Condition excluding the views of PeopleTools records
+const dbnotrec = "not in (select decode(sqltablename, ' ', 'PS_' || recname, sqltablename) from psrecdefn)"
+
+func srchdb(db *sql.DB, tgts []target) ([]Finding, error) {
+	// The targets are indexed by table rather than record
+	tbltgts := make([]target, len(tgts))
+	for i, t := range tgts {
+		tbltgts[i] = target{t.c, t.c.table(), t.col}
+	}
+	x := newtgtindex(tbltgts, func(s string) []byte { return bytes.ToUpper([]byte(s)) })
+	ids := x.ids(false)
+
+	var fs []Finding
+	hit := map[string]map[int]bool{} // Targets found in each object
+
+	// Report the targets found in the source of one object, at the first line of each pattern
+	srch := func(key, typ string, lines [][]byte, nums []int) {
+		found := map[int]int{}
+		state := 0
+		for n, line := range lines {
+			for _, tok := range plsqltokens(line, &state) {
+				if id, ok := ids[string(tok)]; ok {
+					if _, seen := found[id]; !seen {
+						found[id] = n
+					}
+				}
+			}
+		}
+		set := map[int]bool{}
+		for id := range found {
+			set[id] = true
+		}
+		ts := x.hits(set)
+		sort.Ints(ts)
+		if hit[key] == nil {
+			hit[key] = map[int]bool{}
+		}
+		for _, i := range ts {
+			n := found[x.recpat[i]]
+			if c := x.colpat[i]; c >= 0 {
+				n = found[c]
+			}
+			loc := strings.ToLower(typ)
+			if nums != nil {
+				loc += " line: " + strconv.Itoa(nums[n])
+			}
+			hit[key][i] = true
+			fs = append(fs, Finding{Change: tgts[i].c, Kind: kinddb, Key: key, Location: loc, Snippet: strings.Join(strings.Fields(string(lines[n])), " ")})
+		}
+	}
+
+	// PL/SQL units, one row per line
+	rows, err := db.Query("select owner, name, type, line, text from all_source where owner = " + curschema + " and type in (" + dbsrctypes + ") order by 1, 2, 3, 4")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var key, typ string
+	var lines [][]byte
+	var nums []int
+	for rows.Next() {
+		var owner, name, t string
+		var n int
+		var text []byte
+		if err = rows.Scan(&owner, &name, &t, &n, &text); err != nil {
+			return nil, err
+		}
+		if k := owner + "." + name; k != key || t != typ {
+			if key != "" {
+				srch(key, typ, lines, nums)
+			}
+			key, typ, lines, nums = k, t, nil, nil
+		}
+		lines = append(lines, bytes.TrimRight(text, "\r\n"))
+		nums = append(nums, n)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if key != "" {
+		srch(key, typ, lines, nums)
+	}
+
+	// Views and materialized views; the text is searched as a whole
+	for _, q := range []string{
+		"select owner, view_name, 'VIEW', text from all_views where owner = " + curschema + " and view_name " + dbnotrec + " order by 2",
+		"select owner, mview_name, 'MATERIALIZED VIEW', query from all_mviews where owner = " + curschema + " order by 2",
+	} {
+		rows, err := db.Query(q)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var owner, name, t string
+			var text []byte
+			if err = rows.Scan(&owner, &name, &t, &text); err != nil {
+				return nil, err
+			}
+			srch(owner+"."+name, t, [][]byte{bytes.Replace(text, []byte("\n"), []byte(" "), -1)}, nil)
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Dependencies on changed tables not named in the text, directly or through a private or
+	// public synonym of the table
+	rows, err = db.Query("select d.owner, d.name, d.type, d.referenced_name, nvl(s.table_name, d.referenced_name) from all_dependencies d left join all_synonyms s on d.referenced_type = 'SYNONYM' and s.owner = d.referenced_owner and s.synonym_name = d.referenced_name where d.owner = " + curschema + " and ((d.referenced_type in ('TABLE', 'VIEW') and d.referenced_owner = " + curschema + ") or (d.referenced_type = 'SYNONYM' and s.table_owner = " + curschema + ")) and (d.type in (" + dbsrctypes + ", 'MATERIALIZED VIEW') or (d.type = 'VIEW' and d.name " + dbnotrec + ")) order by 1, 2, 3, 4")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var owner, name, t, ref, tbl string
+		if err = rows.Scan(&owner, &name, &t, &ref, &tbl); err != nil {
+			return nil, err
+		}
+		id, ok := ids[strings.ToUpper(tbl)]
+		if !ok {
+			continue
+		}
+		loc := strings.ToLower(t) + " depends on " + ref
+		if tbl != ref {
+			loc += ", a synonym of " + tbl
+		}
+		k := owner + "." + name
+		for _, i := range x.byrec[id] {
+			if x.colpat[i] < 0 && !hit[k][i] {
+				if hit[k] == nil {
+					hit[k] = map[int]bool{}
+				}
+				hit[k][i] = true
+				fs = append(fs, Finding{Change: tgts[i].c, Kind: kinddb, Key: k, Location: loc})
+			}
+		}
+	}
+	return fs, rows.Err()
+}
+
+// Upper-cased identifiers on one line of PL/SQL or SQL, including the words of 'quoted' literals,
+// where dynamic SQL names tables. -- comments and /* */ comments are skipped; state carries an
+// open comment or literal over to the next line. A "quoted" identifier is one token.
+func plsqltokens(line []byte, state *int) [][]byte {
+	const (
+		code = iota
+		block
+		literal
+	)
+	var toks [][]byte
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case *state == block:
+			k := bytes.Index(line[i:], []byte("*/"))
+			if k < 0 {
+				return toks
+			}
+			i += k + 2
+			*state = code
+		case c == '\'':
+			if *state == literal && i+1 < len(line) && line[i+1] == '\'' {
+				i += 2 // quote inside the literal
+				continue
+			}
+			if *state == literal {
+				*state = code
+			} else {
+				*state = literal
+			}
+			i++
+		case *state == code && bytes.HasPrefix(line[i:], []byte("--")):
+			return toks
+		case *state == code && bytes.HasPrefix(line[i:], []byte("/*")):
+			*state = block
+			i += 2
+		case *state == code && c == '"':
+			k := bytes.IndexByte(line[i+1:], '"')
+			if k < 0 {
+				return toks
+			}
+			toks = append(toks, line[i+1:i+1+k])
+			i += k + 2
+		case identchar(c, true) && c != '$' && c != '#':
+			j := i
+			for i++; i < len(line) && identchar(line[i], true); i++ {
+			}
+			toks = append(toks, bytes.ToUpper(line[j:i]))
+		default:
+			i++
+		}
+	}
+	return toks
+}
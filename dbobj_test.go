@@ -0,0 +1,17 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import "testing"
+
+func TestPlsqltokens(t *testing.T) {
+	lines := []string{"select emplid -- from ps_x", "from PS_JOB /* ps_y", "ps_z */ where x = 'it''s -- PS_W", "rem' and \"Ps_q\""}
+	want := []string{"SELECT EMPLID", "FROM PS_JOB", "WHERE X IT S PS_W", "REM AND Ps_q"}
+	state := 0
+	for i, l := range lines {
+		if got := joined(plsqltokens([]byte(l), &state)); got != want[i] {
+			t.Errorf("plsqltokens(%q) = %q, want %q", l, got, want[i])
+		}
+	}
+}
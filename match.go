@@ -0,0 +1,90 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+// Aho–Corasick automaton finding any number of byte patterns in a single pass over a text.
+// Bytes that occur in no pattern share one class, which keeps the transition table small.
+
+type matcher struct {
+	class  [256]int // Byte class; 0 for bytes not in any pattern
+	nclass int
+	next   []int32 // Transitions by state*nclass+class, completed with the failure links
+	out    [][]int // Patterns ending in each state
+}
+
+func newmatcher(pats [][]byte) *matcher {
+	m := &matcher{nclass: 1}
+	for _, p := range pats {
+		for _, c := range p {
+			if m.class[c] == 0 {
+				m.class[c] = m.nclass
+				m.nclass++
+			}
+		}
+	}
+	m.addstate()
+
+	// Trie of the patterns
+	for id, p := range pats {
+		if len(p) == 0 {
+			continue
+		}
+		s := 0
+		for _, c := range p {
+			i := s*m.nclass + m.class[c]
+			if m.next[i] < 0 {
+				m.next[i] = int32(m.addstate())
+			}
+			s = int(m.next[i])
+		}
+		m.out[s] = append(m.out[s], id)
+	}
+
+	// Breadth-first failure links
+	fail := make([]int, len(m.out))
+	var queue []int
+	for c := 0; c < m.nclass; c++ {
+		if t := m.next[c]; t < 0 {
+			m.next[c] = 0
+		} else {
+			queue = append(queue, int(t))
+		}
+	}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		m.out[s] = append(m.out[s], m.out[fail[s]]...)
+		for c := 0; c < m.nclass; c++ {
+			t := m.next[s*m.nclass+c]
+			if t < 0 {
+				m.next[s*m.nclass+c] = m.next[fail[s]*m.nclass+c]
+				continue
+			}
+			fail[t] = int(m.next[fail[s]*m.nclass+c])
+			queue = append(queue, int(t))
+		}
+	}
+	return m
+}
+
+func (m *matcher) addstate() int {
+	for c := 0; c < m.nclass; c++ {
+		m.next = append(m.next, -1)
+	}
+	m.out = append(m.out, nil)
+	return len(m.out) - 1
+}
+
+// Distinct patterns found in text
+func (m *matcher) find(text []byte) map[int]bool {
+	found := map[int]bool{}
+	s := int32(0)
+	for _, c := range text {
+		s = m.next[int(s)*m.nclass+m.class[c]]
+		for _, id := range m.out[s] {
+			found[id] = true
+		}
+	}
+	return found
+}
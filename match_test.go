@@ -0,0 +1,29 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	pats := [][]byte{[]byte("PS_JOB"), []byte("JOB"), []byte("EMPLID"), []byte("PLID"), []byte(""), []byte("JOB")}
+	m := newmatcher(pats)
+	tests := []struct {
+		text string
+		want map[int]bool
+	}{
+		{"select EMPLID from PS_JOB", map[int]bool{0: true, 1: true, 2: true, 3: true, 5: true}},
+		{"from PS_JO", map[int]bool{}},
+		{"JOBJOB", map[int]bool{1: true, 5: true}},
+		{"ps_job", map[int]bool{}},
+		{"", map[int]bool{}},
+	}
+	for _, tt := range tests {
+		if got := m.find([]byte(tt.text)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("find(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
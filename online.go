@@ -0,0 +1,308 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Once all changes are known the custom SQL, PeopleCode and Query definitions in the projects
+// containing custom objects are selected a single time by their scanners, and each object is
+// scanned once for all changes with one multi-pattern matcher.
+
+// Change searched for in custom objects. col is empty for record level searches.
+type target struct {
+	c        *Change
+	rec, col string
+}
+
+// Targets of the changes searched for in online objects
+func onltargets(chgs []Change) []target {
+	var tgts []target
+	for i := range chgs {
+		if rec, col, ok := chgs[i].onltarget(); ok {
+			tgts = append(tgts, target{&chgs[i], rec, col})
+		}
+	}
+	return tgts
+}
+
+// Patterns of the targets, with a lookup from each record pattern to its targets
+type tgtindex struct {
+	pats   [][]byte
+	byrec  map[int][]int // Targets by record pattern
+	recpat []int         // Record pattern of each target
+	colpat []int         // Field pattern of each target, -1 for record level searches
+	tbls   [][]byte      // Database table of the record of each target
+}
+
+func newtgtindex(tgts []target, enc func(string) []byte) *tgtindex {
+	x := &tgtindex{byrec: map[int][]int{}, recpat: make([]int, len(tgts)), colpat: make([]int, len(tgts)), tbls: make([][]byte, len(tgts))}
+	ids := map[string]int{}
+	pat := func(s string) int {
+		id, ok := ids[s]
+		if !ok {
+			id = len(x.pats)
+			ids[s] = id
+			x.pats = append(x.pats, enc(s))
+		}
+		return id
+	}
+	for i, t := range tgts {
+		r := pat(t.rec)
+		x.byrec[r] = append(x.byrec[r], i)
+		x.recpat[i] = r
+		x.colpat[i] = -1
+		x.tbls[i] = enc(t.c.table())
+		if t.col != "" {
+			x.colpat[i] = pat(t.col)
+		}
+	}
+	return x
+}
+
+// Pattern of each name, for matching identifiers. With tables, records are also found by their
+// database table name, as in SQL text.
+func (x *tgtindex) ids(tables bool) map[string]int {
+	ids := map[string]int{}
+	for id, p := range x.pats {
+		ids[string(p)] = id
+	}
+	if tables {
+		for i, r := range x.recpat {
+			if _, ok := ids[string(x.tbls[i])]; !ok {
+				ids[string(x.tbls[i])] = r
+			}
+		}
+	}
+	return ids
+}
+
+// Targets whose record, and field if any, were found
+func (x *tgtindex) hits(found map[int]bool) []int {
+	var ts []int
+	for id := range found {
+		for _, i := range x.byrec[id] {
+			if c := x.colpat[i]; c < 0 || found[c] {
+				ts = append(ts, i)
+			}
+		}
+	}
+	return ts
+}
+
+// Targets of a record: record level ones, and those of the field when fld is not empty
+func rectargets(byrec map[string][]int, tgts []target, rec, fld string) []int {
+	var ts []int
+	for _, i := range byrec[rec] {
+		if tgts[i].col == "" || (fld != "" && tgts[i].col == fld) {
+			ts = append(ts, i)
+		}
+	}
+	return ts
+}
+
+// Index of the targets by record
+func tgtsbyrec(tgts []target) map[string][]int {
+	byrec := map[string][]int{}
+	for i, t := range tgts {
+		byrec[t.rec] = append(byrec[t.rec], i)
+	}
+	return byrec
+}
+
+// Search of one kind of custom online object for the targets
+type onlsearch func(db *sql.DB, tgts []target) ([]Finding, error)
+
+// Scanner of custom online objects selected from the upgraded database
+type onlscanner struct {
+	name, title string
+	srch        []onlsearch
+}
+
+func (s onlscanner) Name() string {
+	return s.name
+}
+
+// Search the custom online objects for all changes and report the findings per change
+func (s onlscanner) Scan(db *sql.DB, chgs []Change) ([]Finding, error) {
+	tgts := onltargets(chgs)
+	if len(tgts) == 0 {
+		return nil, nil
+	}
+
+	var fs []Finding
+	for _, srch := range s.srch {
+		f, err := srch(db, tgts)
+		if err != nil {
+			return nil, err
+		}
+		fs = append(fs, f...)
+	}
+
+	return fs, prtfindings(s.title, chgs, fs)
+}
+
+// Print and log the findings grouped per change
+func prtfindings(title string, chgs []Change, fs []Finding) error {
+	file1, err := os.OpenFile(logfile, os.O_RDWR|os.O_APPEND, 0666)
+	if err != nil {
+		panic(err)
+	}
+
+	defer file1.Close()
+
+	fmt.Print("\n" + title + " :\n")
+	file1.WriteString("\n\n" + title + " :\n")
+
+	fs = append([]Finding(nil), fs...)
+	sortfindings(chgs, fs)
+	var c *Change
+	seen := map[string]bool{}
+	for _, f := range fs {
+		if f.Change != c {
+			c = f.Change
+			seen = map[string]bool{}
+			fmt.Println(c.Category, ":", c)
+			file1.WriteString(c.Category + " : " + c.String() + "\n")
+		}
+		line := f.String()
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		println(line)
+		file1.WriteString(line)
+		file1.WriteString("\n")
+	}
+	return nil
+}
+
+func srchsql(db *sql.DB, tgts []target) ([]Finding, error) {
+
+	// Only searches for the SQL id the sqlid exists in the projects that contain custom objects (UPGCUST) created during the initial upgrade
+
+	ph, args := custbinds()
+	rows, err := db.Query("select sqlid, case sqltype when '0' then 'Other' when '1' then 'App Engine' when '2' then 'View' end sqltype, market, dbtype, effdt, sqltext from pssqltextdefn where sqlid in (select objectvalue1 from psprojectitem where projectname in ("+ph+") and objecttype = 30) order by 1,2,3,4,5,seqnum", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Records are also found by their database table name
+	x := newtgtindex(tgts, func(s string) []byte { return []byte(s) })
+	pats := append([][]byte(nil), x.pats...)
+	tblpat := map[int]int{} // Record pattern of each table pattern
+	seen := map[string]bool{}
+	for i, r := range x.recpat {
+		if seen[string(x.tbls[i])] {
+			continue // one pattern per table, not per target
+		}
+		seen[string(x.tbls[i])] = true
+		tblpat[len(pats)] = r
+		pats = append(pats, x.tbls[i])
+	}
+	m := newmatcher(pats)
+
+	var fs []Finding
+	var key, sqlid, sqltype string
+	var text []byte
+	flush := func() {
+		if key == "" {
+			return
+		}
+		found := m.find(text)
+		for id := range found {
+			if r, ok := tblpat[id]; ok {
+				found[r] = true
+			}
+		}
+		ts := x.hits(found)
+		sort.Ints(ts)
+		for _, i := range ts {
+			fs = append(fs, Finding{Change: tgts[i].c, Kind: kindsql, Key: sqlid + " - " + sqltype, Snippet: string(text)})
+		}
+	}
+	for rows.Next() {
+		var s1, s2, s3, s4, s6 string
+		var s5 sql.NullString
+		if err = rows.Scan(&s1, &s2, &s3, &s4, &s5, &s6); err != nil {
+			return nil, err
+		}
+		if k := s1 + "|" + s2 + "|" + s3 + "|" + s4 + "|" + s5.String; k != key {
+			flush()
+			key, sqlid, sqltype, text = k, s1, s2, nil
+		}
+		text = append(text, s6...)
+	}
+	flush()
+	return fs, rows.Err()
+}
+
+func srchqryrec(db *sql.DB, tgts []target) ([]Finding, error) {
+
+	// Only searches for the Query if it exists in the projects that contain custom objects (UPGCUST) created during the initial upgrade
+
+	byrec := tgtsbyrec(tgts)
+	return srchqry(db, tgts, "select distinct oprid, qryname, recname, ' ' fieldname, ' ' loc, ' ' snippet from psqryrecord", func(rec, fld string) []int {
+		return byrec[rec]
+	})
+}
+
+func srchqryfld(db *sql.DB, tgts []target) ([]Finding, error) {
+
+	// Only searches for the Query if it exists in the projects that contain custom objects (UPGCUST) created during the initial upgrade
+
+	byfld := map[string][]int{}
+	for i, t := range tgts {
+		if t.col != "" {
+			byfld[t.rec+"."+t.col] = append(byfld[t.rec+"."+t.col], i)
+		}
+	}
+	return srchqry(db, tgts, "select distinct oprid, qryname, recname, fieldname, ' ' loc, ' ' snippet from psqryfield", func(rec, fld string) []int {
+		return byfld[rec+"."+fld]
+	})
+}
+
+// Condition selecting the queries of the projects containing custom objects
+// objecttype 10 = Query
+func qryprj(ph string) string {
+	return "(oprid, qryname) in (select objectvalue2, objectvalue1 from psprojectitem where projectname in (" + ph + ") and objecttype = 10)"
+}
+
+// Select the rows of custom queries once and report the targets each row matches. The query
+// selects the operator, query, record, field, location and snippet of each row.
+func srchqry(db *sql.DB, tgts []target, query string, match func(rec, fld string) []int) ([]Finding, error) {
+	ph, args := custbinds()
+	rows, err := db.Query("select * from ("+query+") where "+qryprj(ph)+" order by 2, 1", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fs []Finding
+	for rows.Next() {
+		var q1, q2, q3, q4, q5, q6 sql.NullString
+		if err = rows.Scan(&q1, &q2, &q3, &q4, &q5, &q6); err != nil {
+			return nil, err
+		}
+		obj := qrykey(q1.String, q2.String)
+		for _, i := range match(strings.TrimSpace(q3.String), strings.TrimSpace(q4.String)) {
+			fs = append(fs, Finding{Change: tgts[i].c, Kind: kindqry, Key: obj, Location: strings.TrimSpace(q5.String), Snippet: strings.TrimSpace(q6.String)})
+		}
+	}
+	return fs, rows.Err()
+}
+
+// Key of a query; private queries are keyed by query and operator id
+func qrykey(oprid, qryname string) string {
+	if oprid = strings.TrimSpace(oprid); oprid != "" {
+		return strings.TrimSpace(qryname) + " : " + oprid
+	}
+	return strings.TrimSpace(qryname)
+}
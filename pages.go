@@ -0,0 +1,85 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// Pages and components fail when they are opened, not when code runs, so they are matched against
+// their definitions: the record fields placed on each custom page (PSPNLFIELD), and the search and
+// add search records of each custom component (PSPNLGRPDEFN).
+
+// Condition selecting the definitions of the projects containing custom objects
+// objecttype 5 = Page
+// objecttype 7 = Component
+func defnprj(col string, objtype int, ph string) string {
+	return col + " in (select objectvalue1 from psprojectitem where projectname in (" + ph + ") and objecttype = " + strconv.Itoa(objtype) + ")"
+}
+
+func srchpage(db *sql.DB, tgts []target) ([]Finding, error) {
+
+	// Only searches for the pages in the projects that contain custom objects (UPGCUST) created during the initial upgrade
+
+	byrec := tgtsbyrec(tgts)
+	ph, args := custbinds()
+	rows, err := db.Query("select pnlname, fieldnum, recname, fieldname from pspnlfield where "+defnprj("pnlname", 5, ph)+" and recname <> ' ' order by 1, 2", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fs []Finding
+	for rows.Next() {
+		var pnl, rec, fld string
+		var num int
+		if err = rows.Scan(&pnl, &num, &rec, &fld); err != nil {
+			return nil, err
+		}
+		pnl, rec, fld = strings.TrimSpace(pnl), strings.TrimSpace(rec), strings.TrimSpace(fld)
+		ref := rec
+		if fld != "" {
+			ref += "." + fld
+		}
+		for _, i := range rectargets(byrec, tgts, rec, fld) {
+			fs = append(fs, Finding{Change: tgts[i].c, Kind: kindpage, Key: pnl, Location: "field " + strconv.Itoa(num), Snippet: ref})
+		}
+	}
+	return fs, rows.Err()
+}
+
+func srchcomp(db *sql.DB, tgts []target) ([]Finding, error) {
+
+	// Only searches for the components in the projects that contain custom objects (UPGCUST) created during the initial upgrade
+
+	byrec := tgtsbyrec(tgts)
+	ph, args := custbinds()
+	rows, err := db.Query("select pnlgrpname, market, searchrecname, addsrchrecname from pspnlgrpdefn where "+defnprj("pnlgrpname", 7, ph)+" order by 1, 2", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fs []Finding
+	for rows.Next() {
+		var comp, market, srch, add string
+		if err = rows.Scan(&comp, &market, &srch, &add); err != nil {
+			return nil, err
+		}
+		key := strings.TrimSpace(comp) + "." + strings.TrimSpace(market)
+		for _, r := range []struct{ loc, rec string }{{"search record", srch}, {"add search record", add}} {
+			rec := strings.TrimSpace(r.rec)
+			if rec == "" {
+				continue
+			}
+			// A field change of a search record changes the search page
+			for _, i := range byrec[rec] {
+				fs = append(fs, Finding{Change: tgts[i].c, Kind: kindcomp, Key: key, Location: r.loc, Snippet: tgts[i].c.String()})
+			}
+		}
+	}
+	return fs, rows.Err()
+}
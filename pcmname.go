@@ -0,0 +1,170 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+// PeopleTools keeps the definitions each PeopleCode program references in PSPCMNAME. A
+// Record.Field reference, including the FUNCLIB record and field of a declared function, has the
+// record in RECNAME and the field in REFNAME; a definition reference such as Record.JOB or
+// SQL.MY_SQL has the definition type in RECNAME and the name in REFNAME. Changed records and
+// fields are matched exactly against these references. Referenced SQL definitions are read from
+// PSSQLTEXTDEFN and searched in turn, so a program is also impacted through the SQL it runs.
+
+// Reference of a PeopleCode program to a record, field or SQL definition
+type pcref struct {
+	rec, fld string // Record and field; fld is empty for Record.X references
+	sqlid    string // SQL definition of SQL.X references
+}
+
+// Search the custom PeopleCode programs for the targets: their PSPCMNAME references, then their
+// text for what the references do not cover, such as table names in SQL strings. A program is
+// reported once per change, from its references where they cover the change.
+func srchpc(db *sql.DB, tgts []target) ([]Finding, error) {
+	fs, err := srchpcmname(db, tgts)
+	if err != nil {
+		return nil, err
+	}
+	txt, err := srchpcode(db, tgts)
+	if err != nil {
+		return nil, err
+	}
+
+	type progchg struct {
+		key string
+		c   *Change
+	}
+	seen := map[progchg]bool{}
+	for _, f := range fs {
+		seen[progchg{f.Key, f.Change}] = true
+	}
+	for _, f := range txt {
+		if !seen[progchg{f.Key, f.Change}] {
+			seen[progchg{f.Key, f.Change}] = true
+			fs = append(fs, f)
+		}
+	}
+	return fs, nil
+}
+
+// Search the references of the custom PeopleCode programs for the targets
+func srchpcmname(db *sql.DB, tgts []target) ([]Finding, error) {
+	byrec := tgtsbyrec(tgts)
+
+	// References of each program, in program order
+	type pcprog struct {
+		key, event string
+		refs       []pcref
+	}
+	var progs []*pcprog
+	var prog *pcprog
+
+	ph, args := custbinds()
+	rows, err := db.Query("select "+pcodecols+", recname, refname from pspcmname where "+pcodeprj(ph)+" order by 1,2,3,4,5,6,7,namenum", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		vals := make([]string, 7)
+		var recname, refname string
+		if err = scanpc(rows, vals, &recname, &refname); err != nil {
+			return nil, err
+		}
+		key, event := pckey(vals)
+		if prog == nil || prog.key != key {
+			prog = &pcprog{key: key, event: event}
+			progs = append(progs, prog)
+		}
+		recname, refname = strings.TrimSpace(recname), strings.TrimSpace(refname)
+		switch recname {
+		case "RECORD":
+			prog.refs = append(prog.refs, pcref{rec: refname})
+		case "SQL":
+			prog.refs = append(prog.refs, pcref{sqlid: refname})
+		default:
+			prog.refs = append(prog.refs, pcref{rec: recname, fld: refname})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sqlhits, err := srchsqlrefs(db, tgts)
+	if err != nil {
+		return nil, err
+	}
+
+	var fs []Finding
+	for _, p := range progs {
+		seen := map[int]bool{}
+		for _, r := range p.refs {
+			var ts []int
+			var ref string
+			if r.sqlid != "" {
+				ref = "SQL." + r.sqlid
+				ts = sqlhits[r.sqlid]
+			} else {
+				ref = r.rec
+				if r.fld != "" {
+					ref += "." + r.fld
+				}
+				ts = rectargets(byrec, tgts, r.rec, r.fld)
+			}
+			for _, i := range ts {
+				if !seen[i] {
+					seen[i] = true
+					fs = append(fs, Finding{Change: tgts[i].c, Kind: kindpcode, Key: p.key, Location: "event " + p.event, Snippet: ref})
+				}
+			}
+		}
+	}
+	return fs, nil
+}
+
+// Targets found in each SQL definition referenced by the custom PeopleCode programs
+func srchsqlrefs(db *sql.DB, tgts []target) (map[string][]int, error) {
+	x := newtgtindex(tgts, func(s string) []byte { return []byte(strings.ToUpper(s)) })
+	ids := x.ids(true)
+
+	// The text of a definition is split over rows by SEQNUM
+	ph, args := custbinds()
+	rows, err := db.Query("select sqlid, sqltext from pssqltextdefn where sqlid in (select refname from pspcmname where recname = 'SQL' and "+pcodeprj(ph)+") order by sqlid, sqltype, market, dbtype, effdt, seqnum", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	text := map[string][]byte{}
+	for rows.Next() {
+		var sqlid string
+		var t []byte
+		if err = rows.Scan(&sqlid, &t); err != nil {
+			return nil, err
+		}
+		text[sqlid] = append(text[sqlid], t...)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hits := map[string][]int{}
+	for sqlid, t := range text {
+		found := map[int]bool{}
+		for _, tok := range sqrtokens(t, true) {
+			if id, ok := ids[string(tok)]; ok {
+				found[id] = true
+			}
+		}
+		ts := x.hits(found)
+		sort.Ints(ts)
+		hits[sqlid] = ts
+	}
+	return hits, nil
+}
@@ -0,0 +1,294 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PeopleCode is searched as source text. The source is read from PSPCMTXT where the tools
+// release keeps it. Otherwise only a best-effort keyword search of PSPCMPROG.PROGTXT is made:
+// there is no decoder of the PROGTXT opcodes into statements yet, so only the names and literals
+// the compiled program stores as null terminated UTF-16 strings are recovered, and these
+// findings have no line or source text. References compiled into the name table (PSPCMNAME) are
+// not in this text.
+
+// Columns identifying a PeopleCode program
+const pcodecols = "objectvalue1, objectvalue2, objectvalue3, objectvalue4, objectvalue5, objectvalue6, objectvalue7"
+
+// Key of a PeopleCode program, its non-blank object values joined by dots, and its event, the
+// last of them. The seven 30 character object values of an App Package program make keys of up
+// to 216 characters, which UPGRADE_AUDIT.PCODE_OBJECT holds.
+func pckey(vals []string) (key, event string) {
+	var vs []string
+	for _, v := range vals {
+		if v = strings.TrimSpace(v); v != "" {
+			vs = append(vs, v)
+		}
+	}
+	if len(vs) > 0 {
+		event = vs[len(vs)-1]
+	}
+	return strings.Join(vs, "."), event
+}
+
+// Scan the object values of a PeopleCode program and the columns after them
+func scanpc(rows *sql.Rows, vals []string, dest ...interface{}) error {
+	d := make([]interface{}, 0, len(vals)+len(dest))
+	for i := range vals {
+		d = append(d, &vals[i])
+	}
+	return rows.Scan(append(d, dest...)...)
+}
+
+// Condition selecting the PeopleCode of the projects containing custom objects
+// objecttype 43 = App Engine PeopleCode
+// objecttype 58 = App Package PeopleCode
+// objecttype 46 = Component PeopleCode
+// objecttype 48 = Component Rec Fld PeopleCode
+// objecttype 47 = Component Record PeopleCode
+// objecttype 44 = Page PeopleCode
+// objecttype 8 = Record PeopleCode
+func pcodeprj(ph string) string {
+	return "objectvalue1 in (select objectvalue1 from psprojectitem where projectname in (" + ph + ") and objecttype in (8,43,44,46,47,48,58))"
+}
+
+func srchpcode(db *sql.DB, tgts []target) ([]Finding, error) {
+
+	// Only searches for the PCode if it exists in the projects that contain custom objects (UPGCUST) created during the initial upgrade
+
+	x := newtgtindex(tgts, func(s string) []byte { return bytes.ToUpper([]byte(s)) })
+	ids := x.ids(true)
+
+	var fs []Finding
+	err := pcprogs(db, func(vals []string, text []byte, source bool) {
+		obj, event := pckey(vals)
+		found := map[int]int{} // First line of each pattern
+		lines := bytes.Split(text, []byte("\n"))
+		state := 0
+		for n, line := range lines {
+			toks := pcwords(line)
+			if source {
+				toks = pctokens(line, &state)
+			}
+			for _, tok := range toks {
+				if id, ok := ids[string(tok)]; ok {
+					if _, seen := found[id]; !seen {
+						found[id] = n + 1
+					}
+				}
+			}
+		}
+		set := map[int]bool{}
+		for id := range found {
+			set[id] = true
+		}
+		ts := x.hits(set)
+		sort.Ints(ts)
+		for _, i := range ts {
+			n := found[x.recpat[i]]
+			if c := x.colpat[i]; c >= 0 {
+				n = found[c]
+			}
+			if !source {
+				fs = append(fs, Finding{Change: tgts[i].c, Kind: kindpcode, Key: obj, Location: "event " + event})
+				continue
+			}
+			fs = append(fs, Finding{Change: tgts[i].c, Kind: kindpcode, Key: obj, Location: "event " + event + " line: " + strconv.Itoa(n), Snippet: string(bytes.TrimSpace(lines[n-1]))})
+		}
+	})
+	return fs, err
+}
+
+// Call fn with the text of each custom PeopleCode program: its source, or the names and literals
+// of the compiled program when source is false
+func pcprogs(db *sql.DB, fn func(vals []string, text []byte, source bool)) error {
+	ph, args := custbinds()
+	var c int
+	if err := db.QueryRow("select count(1) from all_tables where table_name = 'PSPCMTXT'").Scan(&c); err != nil {
+		return err
+	}
+	if c > 0 {
+		rows, err := db.Query("select "+pcodecols+", pctext from pspcmtxt where "+pcodeprj(ph)+" order by 1,2,3,4,5,6,7", args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			vals := make([]string, 7)
+			var text []byte
+			if err = scanpc(rows, vals, &text); err != nil {
+				return err
+			}
+			fn(vals, text, true)
+		}
+		return rows.Err()
+	}
+
+	// PROGTXT is split over rows by PROGSEQ
+	println(`PSPCMTXT not found. Searching the names and literals of PSPCMPROG.PROGTXT without line numbers.`)
+	rows, err := db.Query("select "+pcodecols+", progtxt from pspcmprog where "+pcodeprj(ph)+" order by 1,2,3,4,5,6,7,progseq", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var obj []string
+	var prog []byte
+	for rows.Next() {
+		vals := make([]string, 7)
+		var progtxt []byte
+		if err = scanpc(rows, vals, &progtxt); err != nil {
+			return err
+		}
+		if obj == nil || strings.Join(vals, "|") != strings.Join(obj, "|") {
+			if obj != nil {
+				fn(obj, pcstrings(prog), false)
+			}
+			obj, prog = vals, nil
+		}
+		prog = append(prog, progtxt...)
+	}
+	if obj != nil {
+		fn(obj, pcstrings(prog), false)
+	}
+	return rows.Err()
+}
+
+// Names and literals of a compiled PeopleCode program: runs of printable ASCII characters stored
+// as UTF-16, each followed by a null character, separated by spaces. The opcodes between the
+// strings are skipped.
+func pcstrings(progtxt []byte) []byte {
+	var text []byte
+	for i := 0; i+1 < len(progtxt); {
+		j := i
+		for j+1 < len(progtxt) && progtxt[j+1] == 0 && progtxt[j] >= 0x20 && progtxt[j] < 0x7f {
+			j += 2
+		}
+		if j > i && j+1 < len(progtxt) && progtxt[j] == 0 && progtxt[j+1] == 0 {
+			s := make([]byte, 0, (j-i)/2)
+			for k := i; k < j; k += 2 {
+				s = append(s, progtxt[k])
+			}
+			if string(s) == ";" {
+				text = append(text, ";\n"...)
+			} else {
+				if len(text) > 0 && text[len(text)-1] != '\n' {
+					text = append(text, ' ')
+				}
+				text = append(text, s...)
+			}
+			i = j + 2
+			continue
+		}
+		i++
+	}
+	return text
+}
+
+// Upper-cased words of the names and literals of a compiled program, which has no comments
+func pcwords(text []byte) [][]byte {
+	var toks [][]byte
+	for i := 0; i < len(text); {
+		if !identchar(text[i], true) {
+			i++
+			continue
+		}
+		j := i
+		for i++; i < len(text) && identchar(text[i], true); i++ {
+		}
+		toks = append(toks, bytes.ToUpper(text[j:i]))
+	}
+	return toks
+}
+
+// Upper-cased identifiers on one line of PeopleCode, including the words of string literals,
+// where SQL text appears. /* */ and <* *> comments and REM statements are skipped; state carries
+// an open comment over to the next line.
+func pctokens(line []byte, state *int) [][]byte {
+	const (
+		code = iota
+		block
+		nested
+		rem
+	)
+	var toks [][]byte
+	word := func(i int) int {
+		j := i
+		for i++; i < len(line) && identchar(line[i], true); i++ {
+		}
+		toks = append(toks, bytes.ToUpper(line[j:i]))
+		return i
+	}
+	stmt := true // At the start of a statement
+	for i := 0; i < len(line); {
+		switch *state {
+		case block, nested:
+			end := "*/"
+			if *state == nested {
+				end = "*>"
+			}
+			k := bytes.Index(line[i:], []byte(end))
+			if k < 0 {
+				return toks
+			}
+			i += k + 2
+			*state = code
+			continue
+		case rem:
+			k := bytes.IndexByte(line[i:], ';')
+			if k < 0 {
+				return toks
+			}
+			i += k + 1
+			*state = code
+			continue
+		}
+
+		c := line[i]
+		switch {
+		case bytes.HasPrefix(line[i:], []byte("/*")):
+			*state = block
+			i += 2
+		case bytes.HasPrefix(line[i:], []byte("<*")):
+			*state = nested
+			i += 2
+		case c == '"':
+			// string literal, scanned for the names in SQL text
+			for i++; i < len(line) && line[i] != '"'; {
+				if identchar(line[i], true) {
+					i = word(i)
+				} else {
+					i++
+				}
+			}
+			i++
+			stmt = false
+		case c == '&' || c == '%':
+			// variable or system variable; meta-SQL such as %Table(JOB) keeps its argument
+			for i++; i < len(line) && identchar(line[i], true); i++ {
+			}
+			stmt = false
+		case identchar(c, true):
+			if stmt && len(line)-i >= 3 && bytes.EqualFold(line[i:i+3], []byte("REM")) && (len(line)-i == 3 || !identchar(line[i+3], true)) {
+				*state = rem
+				i += 3
+				continue
+			}
+			i = word(i)
+			stmt = false
+		case c == ';':
+			stmt = true
+			i++
+		default:
+			i++
+		}
+	}
+	return toks
+}
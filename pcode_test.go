@@ -0,0 +1,53 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPctokens(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  []string // Tokens of each line
+	}{
+		{[]string{`&x = JOB.EMPLID; /* PS_OLD`, `still */ SQLExec("select x from PS_JOB", &y);`}, []string{"JOB EMPLID", "SQLEXEC SELECT X FROM PS_JOB"}},
+		{[]string{`REM JOB.EMPLID;`, `Local string &s = %Table(JOB);`}, []string{"", "LOCAL STRING JOB"}},
+		{[]string{`<* JOB`, `*> DEPT`}, []string{"", "DEPT"}},
+	}
+	for _, tt := range tests {
+		state := 0
+		var got []string
+		for _, l := range tt.lines {
+			got = append(got, joined(pctokens([]byte(l), &state)))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pctokens(%q) = %q, want %q", tt.lines, got, tt.want)
+		}
+	}
+}
+
+// Null terminated UTF-16 string of a compiled program
+func utf16z(s string) []byte {
+	var b []byte
+	for _, c := range []byte(s) {
+		b = append(b, c, 0)
+	}
+	return append(b, 0, 0)
+}
+
+func TestPcstrings(t *testing.T) {
+	var prog []byte
+	prog = append(prog, 0x45, 0x03)
+	prog = append(prog, utf16z("JOB")...)
+	prog = append(prog, 0x11)
+	prog = append(prog, utf16z("EMPLID")...)
+	prog = append(prog, utf16z(";")...)
+	prog = append(prog, 0x07, 0x01)
+	prog = append(prog, utf16z("select x from PS_JOB")...)
+	if got, want := string(pcstrings(prog)), "JOB EMPLID;\nselect x from PS_JOB"; got != want {
+		t.Errorf("pcstrings = %q, want %q", got, want)
+	}
+}
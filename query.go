@@ -0,0 +1,341 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Beyond its records and fields, a query refers to the changes in its criteria (PSQRYCRITERIA),
+// whose fields are numbered in PSQRYFIELD, in its expressions and drilling URLs (PSQRYEXPR), whose
+// text names fields by record alias as in A.EMPLID, and in its prompts (PSQRYBIND), whose prompt
+// table is a record. Each finding names the criterion, expression or prompt and its text.
+
+// Criteria comparing a field of a changed record, on either side. The snippet is the text of the
+// criterion: its left field, the condition, named by its CONDTYPE translate value, and the fields,
+// expressions, constants or prompts on its right.
+func srchqrycrit(db *sql.DB, tgts []target) ([]Finding, error) {
+	byrec := tgtsbyrec(tgts)
+
+	// Expressions, constants and prompts of each query by number
+	ph, args := custbinds()
+	rows, err := db.Query("select oprid, qryname, expnum, exprtext from psqryexpr where "+qryprj(ph), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exprs := map[string]map[int]string{}
+	for rows.Next() {
+		var oprid, qry string
+		var num int
+		var text []byte
+		if err = rows.Scan(&oprid, &qry, &num, &text); err != nil {
+			return nil, err
+		}
+		k := qrykey(oprid, qry)
+		if exprs[k] == nil {
+			exprs[k] = map[int]string{}
+		}
+		exprs[k][num] = strings.Join(strings.Fields(string(text)), " ")
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query("select * from (select c.oprid, c.qryname, f.recname, f.fieldname, c.crtnum, c.negation, "+
+		"nvl((select max(x.xlatlongname) from psxlatitem x where x.fieldname = 'CONDTYPE' and x.fieldvalue = to_char(c.condtype)), 'condition ' || c.condtype) cond, "+
+		qrycritfld("lcrtselnum", "lcrtfldnum")+" lfld, "+qrycritfld("r1crtselnum", "r1crtfldnum")+" r1fld, c.r1crtexpnum, "+qrycritfld("r2crtselnum", "r2crtfldnum")+" r2fld, c.r2crtexpnum "+
+		"from psqrycriteria c, psqryfield f where f.oprid = c.oprid and f.qryname = c.qryname and ((f.selnum = c.lcrtselnum and f.fldnum = c.lcrtfldnum) or (f.selnum = c.r1crtselnum and f.fldnum = c.r1crtfldnum) or (f.selnum = c.r2crtselnum and f.fldnum = c.r2crtfldnum))) "+
+		"where "+qryprj(ph)+" order by 2, 1, 5", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fs []Finding
+	for rows.Next() {
+		var oprid, qry, rec, fld, neg, cond string
+		var num, r1exp, r2exp int
+		var lfld, r1fld, r2fld sql.NullString
+		if err = rows.Scan(&oprid, &qry, &rec, &fld, &num, &neg, &cond, &lfld, &r1fld, &r1exp, &r2fld, &r2exp); err != nil {
+			return nil, err
+		}
+		obj := qrykey(oprid, qry)
+		rhs := func(fld sql.NullString, exp int) string {
+			if fld.Valid {
+				return strings.TrimSpace(fld.String)
+			}
+			return exprs[obj][exp]
+		}
+		text := qrycrittext(strings.TrimSpace(lfld.String), strings.TrimSpace(neg) == "1", strings.TrimSpace(cond), rhs(r1fld, r1exp), rhs(r2fld, r2exp))
+		for _, i := range rectargets(byrec, tgts, strings.TrimSpace(rec), strings.TrimSpace(fld)) {
+			fs = append(fs, Finding{Change: tgts[i].c, Kind: kindqry, Key: obj, Location: "criterion " + strconv.Itoa(num), Snippet: text})
+		}
+	}
+	return fs, rows.Err()
+}
+
+// Scalar subquery of the ALIAS.FIELD text of a criterion's field, null when the criterion has
+// none on that side
+func qrycritfld(selnum, fldnum string) string {
+	return "(select r.corrname || '.' || f.fieldname from psqryfield f, psqryrecord r where f.oprid = c.oprid and f.qryname = c.qryname and f.selnum = c." + selnum + " and f.fldnum = c." + fldnum + " and r.oprid = f.oprid and r.qryname = f.qryname and r.selnum = f.selnum and r.rcdnum = f.fldrcdnum)"
+}
+
+// Text of a criterion: the left field, the condition, negated with not, and the right-hand
+// operands, two of them for between
+func qrycrittext(lhs string, not bool, cond, r1, r2 string) string {
+	if not {
+		cond = "not " + cond
+	}
+	text := []string{lhs, cond}
+	for _, r := range []string{r1, r2} {
+		if r != "" {
+			if len(text) > 2 {
+				text = append(text, "and")
+			}
+			text = append(text, r)
+		}
+	}
+	return strings.Join(text, " ")
+}
+
+// Prompts whose prompt table is a changed record, or whose field changed on it
+func srchqrybind(db *sql.DB, tgts []target) ([]Finding, error) {
+	byrec := tgtsbyrec(tgts)
+	return srchqry(db, tgts, "select oprid, qryname, prompttable recname, fieldname, 'prompt ' || bndnum loc, bndname || ' ' || fieldname || ' prompt table ' || prompttable snippet from psqrybind", func(rec, fld string) []int {
+		return rectargets(byrec, tgts, rec, fld)
+	})
+}
+
+// Expressions naming a field of a changed record through its alias, or a changed table in a
+// subquery
+func srchqryexpr(db *sql.DB, tgts []target) ([]Finding, error) {
+	byrec := tgtsbyrec(tgts)
+	x := newtgtindex(tgts, func(s string) []byte { return []byte(strings.ToUpper(s)) })
+	ids := x.ids(true)
+
+	// Records of the aliases of each query
+	ph, args := custbinds()
+	rows, err := db.Query("select oprid, qryname, corrname, recname from psqryrecord where "+qryprj(ph), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := map[string]map[string]string{}
+	for rows.Next() {
+		var oprid, qry, corr, rec string
+		if err = rows.Scan(&oprid, &qry, &corr, &rec); err != nil {
+			return nil, err
+		}
+		k := qrykey(oprid, qry)
+		if aliases[k] == nil {
+			aliases[k] = map[string]string{}
+		}
+		aliases[k][strings.ToUpper(strings.TrimSpace(corr))] = strings.TrimSpace(rec)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query("select oprid, qryname, expnum, exprtext from psqryexpr where "+qryprj(ph)+" order by 2, 1, 3", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fs []Finding
+	for rows.Next() {
+		var oprid, qry string
+		var num int
+		var text []byte
+		if err = rows.Scan(&oprid, &qry, &num, &text); err != nil {
+			return nil, err
+		}
+		obj := qrykey(oprid, qry)
+		toks, refs := qrytokens(text)
+
+		set := map[int]bool{}
+		for _, r := range refs {
+			if rec, ok := aliases[obj][r[0]]; ok {
+				for _, i := range rectargets(byrec, tgts, rec, r[1]) {
+					set[i] = true
+				}
+			}
+		}
+		found := map[int]bool{}
+		for _, tok := range toks {
+			if id, ok := ids[string(tok)]; ok {
+				found[id] = true
+			}
+		}
+		for _, i := range x.hits(found) {
+			set[i] = true
+		}
+
+		ts := make([]int, 0, len(set))
+		for i := range set {
+			ts = append(ts, i)
+		}
+		sort.Ints(ts)
+		for _, i := range ts {
+			fs = append(fs, Finding{Change: tgts[i].c, Kind: kindqry, Key: obj, Location: "expression " + strconv.Itoa(num), Snippet: strings.Join(strings.Fields(string(text)), " ")})
+		}
+	}
+	return fs, rows.Err()
+}
+
+// Upper-cased identifiers of an expression outside 'quoted' literals, and its qualified
+// ALIAS.FIELD references
+func qrytokens(text []byte) (toks [][]byte, refs [][2]string) {
+	toks = sqrtokens(text, true)
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\'':
+			for i++; i < len(text) && text[i] != '\''; i++ {
+			}
+			i++
+		case c == ':' || c == '%':
+			// bind variable or meta-SQL
+			for i++; i < len(text) && identchar(text[i], true); i++ {
+			}
+		case identchar(c, true):
+			j := i
+			for i++; i < len(text) && identchar(text[i], true); i++ {
+			}
+			if i+1 < len(text) && text[i] == '.' && identchar(text[i+1], true) {
+				k := i + 1
+				for i = k + 1; i < len(text) && identchar(text[i], true); i++ {
+				}
+				refs = append(refs, [2]string{strings.ToUpper(string(text[j : k-1])), strings.ToUpper(string(text[k:i]))})
+			}
+		default:
+			i++
+		}
+	}
+	return toks, refs
+}
+
+// Scanner of the custom queries. The impacted queries are rolled up to the connected queries
+// (PSCONQRSMAP) and pivot grids (PSPGCORE) built on them, directly or through other connected
+// queries.
+type qryscanner struct {
+	onlscanner
+}
+
+func (s qryscanner) Scan(db *sql.DB, chgs []Change) ([]Finding, error) {
+	fs, err := s.onlscanner.Scan(db, chgs)
+	if err != nil || len(fs) == 0 {
+		return fs, err
+	}
+	f, err := qryrollup(db, fs)
+	if err != nil {
+		return nil, err
+	}
+	return append(fs, f...), prtfindings("Connected queries and pivot grids built on the impacted queries", chgs, f)
+}
+
+// Reporting object built on a query or connected query. Nodes are keyed by qrykey, so private
+// queries and connected queries are told apart by operator id.
+type qrydep struct {
+	node, kind, key string
+}
+
+// Findings of the connected queries and pivot grids depending on each impacted query
+func qryrollup(db *sql.DB, fs []Finding) ([]Finding, error) {
+	deps := map[string][]qrydep{} // Dependents of each query (Q:) and connected query (C:)
+	have := func(table string) (bool, error) {
+		var c int
+		err := db.QueryRow("select count(1) from all_tables where table_name = :1", table).Scan(&c)
+		return c > 0, err
+	}
+
+	ok, err := have("PSCONQRSMAP")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		// A connected query uses its owner's private query of the name, or else the public one
+		rows, err := db.Query("select distinct m.oprid, m.conqrsname, q.oprid, q.qryname from psconqrsmap m, psqrydefn q where q.qryname in (m.qrynameparent, m.qrynamechild) and (q.oprid = m.oprid or (q.oprid = ' ' and not exists (select 1 from psqrydefn p where p.oprid = m.oprid and p.qryname = q.qryname))) order by 2, 1")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var oprid, name, qoprid, qry string
+			if err = rows.Scan(&oprid, &name, &qoprid, &qry); err != nil {
+				return nil, err
+			}
+			d := qrydep{"C:" + qrykey(oprid, name), kindcqry, qrykey(oprid, name)}
+			q := "Q:" + qrykey(qoprid, qry)
+			deps[q] = append(deps[q], d)
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Pivot grid data sources: Q = public query, otherwise a public connected query
+	ok, err = have("PSPGCORE")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		rows, err := db.Query("select ptpg_pgridname, ptpg_dstype, ptpg_dsname from pspgcore order by 1")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name, typ, ds string
+			if err = rows.Scan(&name, &typ, &ds); err != nil {
+				return nil, err
+			}
+			src := "C:"
+			if strings.TrimSpace(typ) == "Q" {
+				src = "Q:"
+			}
+			src += strings.TrimSpace(ds)
+			deps[src] = append(deps[src], qrydep{"P:" + strings.TrimSpace(name), kindpgrid, strings.TrimSpace(name)})
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Walk the dependents of each impacted query once per change
+	var rfs []Finding
+	seen := map[*Change]map[string]bool{}
+	for _, f := range fs {
+		if f.Kind != kindqry {
+			continue
+		}
+		qry := f.Key // Query and operator id of a private query
+		if seen[f.Change] == nil {
+			seen[f.Change] = map[string]bool{}
+		}
+		done := seen[f.Change]
+		if done["Q:"+qry] {
+			continue
+		}
+		done["Q:"+qry] = true
+		for q := []string{"Q:" + qry}; len(q) > 0; q = q[1:] {
+			for _, d := range deps[q[0]] {
+				if !done[d.node] {
+					done[d.node] = true
+					rfs = append(rfs, Finding{Change: f.Change, Kind: d.kind, Key: d.key, Location: "via query " + qry})
+					q = append(q, d.node)
+				}
+			}
+		}
+	}
+	return rfs, nil
+}
@@ -0,0 +1,41 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQrytokens(t *testing.T) {
+	toks, refs := qrytokens([]byte("CASE WHEN A.EMPL_RCD = 0 THEN 'B.X' ELSE (SELECT MAX(X.EFFDT) FROM PS_JOB X WHERE X.EMPLID = :1) END"))
+	if !strings.Contains(joined(toks), "FROM PS_JOB X") {
+		t.Errorf("tokens %q do not name PS_JOB", joined(toks))
+	}
+	want := [][2]string{{"A", "EMPL_RCD"}, {"X", "EFFDT"}, {"X", "EMPLID"}}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("refs %q, want %q", refs, want)
+	}
+}
+
+func TestQrycrittext(t *testing.T) {
+	tests := []struct {
+		lhs    string
+		not    bool
+		cond   string
+		r1, r2 string
+		want   string
+	}{
+		{"A.EMPLID", false, "equal to", "B.EMPLID", "", "A.EMPLID equal to B.EMPLID"},
+		{"A.DEPTID", true, "in list", "'10000', '20000'", "", "A.DEPTID not in list '10000', '20000'"},
+		{"A.EFFDT", false, "between", ":1", ":2", "A.EFFDT between :1 and :2"},
+		{"A.TERMINATION_DT", false, "is null", "", "", "A.TERMINATION_DT is null"},
+	}
+	for _, tt := range tests {
+		if got := qrycrittext(tt.lhs, tt.not, tt.cond, tt.r1, tt.r2); got != tt.want {
+			t.Errorf("qrycrittext = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The old release demo is read either through the database link from the upgraded database, or
+// through a second connection when -olddsn is given. The release data dictionaries are loaded
+// into memory and compared in Go, so both ways give the same results.
+
+var (
+	olddsn string  // Connect string of the old release demo; when set no database link is needed
+	olddb  *sql.DB // Connection to the old release demo, nil when the database link is used
+)
+
+// Data dictionary of one release
+type release struct {
+	rectype  map[string]string          // PSRECDEFN record type by record name
+	sqltable map[string]string          // PSRECDEFN.SQLTABLENAME by record name, where set
+	dbfield  map[string]dbfield         // PSDBFIELD by field name
+	recfield map[string]map[string]bool // PSRECFIELD fields by record name, loaded by recfields
+}
+
+type dbfield struct {
+	fieldtype, length int
+}
+
+var newrel, oldrel *release // Loaded on first use
+
+// Connection and table for a PeopleTools table in the old release
+func oldsrc(db *sql.DB, tbl string) (*sql.DB, string) {
+	if olddb != nil {
+		return olddb, tbl
+	}
+	return db, oldtbl(tbl)
+}
+
+// Load the data dictionaries of both releases
+func releases(db *sql.DB) (*release, *release, error) {
+	if newrel != nil {
+		return newrel, oldrel, nil
+	}
+	n, err := loadrel(db, "psrecdefn", "psdbfield")
+	if err != nil {
+		return nil, nil, err
+	}
+	odb, orec := oldsrc(db, "psrecdefn")
+	_, ofld := oldsrc(db, "psdbfield")
+	o, err := loadrel(odb, orec, ofld)
+	if err != nil {
+		return nil, nil, err
+	}
+	newrel, oldrel = n, o
+	return newrel, oldrel, nil
+}
+
+func loadrel(db *sql.DB, rectbl, fldtbl string) (*release, error) {
+	r := &release{rectype: map[string]string{}, sqltable: map[string]string{}, dbfield: map[string]dbfield{}}
+
+	rows, err := db.Query("select recname, rectype, sqltablename from " + rectbl)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var o1, o2, o3 string
+		if err = rows.Scan(&o1, &o2, &o3); err != nil {
+			return nil, err
+		}
+		r.rectype[o1] = o2
+		if o3 = strings.TrimSpace(o3); o3 != "" {
+			r.sqltable[o1] = o3
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows2, err := db.Query("select fieldname, fieldtype, length from " + fldtbl)
+	if err != nil {
+		return nil, err
+	}
+	defer rows2.Close()
+	for rows2.Next() {
+		var o1 string
+		var f dbfield
+		if err = rows2.Scan(&o1, &f.fieldtype, &f.length); err != nil {
+			return nil, err
+		}
+		r.dbfield[o1] = f
+	}
+	return r, rows2.Err()
+}
+
+// Records whose type changed from one record type in the old release to another in the new release
+func rectypechg(db *sql.DB, from, to string) ([]string, error) {
+	n, o, err := releases(db)
+	if err != nil {
+		return nil, err
+	}
+	var recs []string
+	for rec, t := range n.rectype {
+		if t == to && o.rectype[rec] == from {
+			recs = append(recs, rec)
+		}
+	}
+	sort.Strings(recs)
+	return recs, nil
+}
+
+// Number and signed number fields shorter in the new release, with the old and new lengths
+func fldlenchg(db *sql.DB) ([][3]string, error) {
+	n, o, err := releases(db)
+	if err != nil {
+		return nil, err
+	}
+	var flds [][3]string
+	for fld, nf := range n.dbfield {
+		of, ok := o.dbfield[fld]
+		if ok && nf.fieldtype == of.fieldtype && (nf.fieldtype == 2 || nf.fieldtype == 3) && nf.length < of.length {
+			flds = append(flds, [3]string{fld, strconv.Itoa(of.length), strconv.Itoa(nf.length)})
+		}
+	}
+	sort.Slice(flds, func(i, j int) bool { return flds[i][0] < flds[j][0] })
+	return flds, nil
+}
+
+// Database table of a record: its SQLTABLENAME, or PS_ and the record name
+func (r *release) table(rec string) string {
+	if t, ok := r.sqltable[rec]; ok {
+		return t
+	}
+	return "PS_" + rec
+}
+
+// Record types with a database table or view: table, view, query view and temporary table
+var dbrectypes = map[string]bool{"0": true, "1": true, "6": true, "7": true}
+
+// Records in both releases whose database table or view is named differently, with the old and
+// new names
+func tblnamechg(db *sql.DB) ([][3]string, error) {
+	n, o, err := releases(db)
+	if err != nil {
+		return nil, err
+	}
+	var recs [][3]string
+	for rec, t := range n.rectype {
+		ot, ok := o.rectype[rec]
+		if ok && dbrectypes[t] && dbrectypes[ot] && o.table(rec) != n.table(rec) {
+			recs = append(recs, [3]string{rec, o.table(rec), n.table(rec)})
+		}
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i][0] < recs[j][0] })
+	return recs, nil
+}
+
+// Set the database table of the record of each change as custom code knows it: from the old
+// release, or the new one for records new in it
+func settables(db *sql.DB, chgs []Change) error {
+	n, o, err := releases(db)
+	if err != nil {
+		return err
+	}
+	for i := range chgs {
+		c := &chgs[i]
+		if c.Record == "" || c.Table != "" {
+			continue
+		}
+		r := o
+		if _, ok := o.rectype[c.Record]; !ok {
+			r = n
+		}
+		c.Table = r.table(c.Record)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// A Scanner searches one source of custom objects for the changes. Scanners are registered by
+// name with the modes that run them by default; -scanners selects them by name instead.
+
+type Scanner interface {
+	Name() string
+	Scan(db *sql.DB, chgs []Change) ([]Finding, error)
+}
+
+// Registered scanner with the modes running it by default
+type scanentry struct {
+	s     Scanner
+	modes []int
+}
+
+var (
+	registry  []scanentry // Scanners in the order they run
+	scannames []string    // Scanners selected on the command line, empty for the mode's default
+)
+
+func init() {
+	register(sqrscanner{}, 2, 4)
+	register(onlscanner{"sql", "Custom SQL referencing the changes", []onlsearch{srchsql}}, 3, 4)
+	register(onlscanner{"pcode", "Custom PeopleCode referencing the changes", []onlsearch{srchpc}}, 3, 4)
+	register(qryscanner{onlscanner{"query", "Custom queries referencing the changes", []onlsearch{srchqryrec, srchqryfld, srchqrycrit, srchqryexpr, srchqrybind}}}, 3, 4)
+	register(onlscanner{"appengine", "Custom App Engine programs referencing the changes", []onlsearch{srchae}}, 3, 4)
+	register(onlscanner{"page", "Custom pages referencing the changes", []onlsearch{srchpage}}, 3, 4)
+	register(onlscanner{"component", "Custom components referencing the changes", []onlsearch{srchcomp}}, 3, 4)
+	register(onlscanner{"dbobject", "Custom database objects referencing the changes", []onlsearch{srchdb}}, 3, 4)
+	register(cblscanner{}, 2, 4)
+}
+
+// Register a scanner, run by default in the given modes
+func register(s Scanner, modes ...int) {
+	registry = append(registry, scanentry{s, modes})
+}
+
+// Names of the registered scanners, in the order they run
+func scannerlist() string {
+	var names []string
+	for _, e := range registry {
+		names = append(names, e.s.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+// Check that the selected scanners are registered
+func chkscanners() error {
+	for _, n := range scannames {
+		if lookupscanner(n) == nil {
+			return fmt.Errorf("unknown scanner: %s (available: %s)", n, scannerlist())
+		}
+	}
+	return nil
+}
+
+func lookupscanner(name string) Scanner {
+	for _, e := range registry {
+		if e.s.Name() == name {
+			return e.s
+		}
+	}
+	return nil
+}
+
+// Scanners to run: those selected by name, otherwise the defaults of the mode
+func runscanners() []Scanner {
+	var ss []Scanner
+	if len(scannames) > 0 {
+		for _, n := range scannames {
+			ss = append(ss, lookupscanner(n))
+		}
+		return ss
+	}
+	for _, e := range registry {
+		for _, m := range e.modes {
+			if m == mode {
+				ss = append(ss, e.s)
+			}
+		}
+	}
+	return ss
+}
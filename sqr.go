@@ -0,0 +1,286 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// The SQR directories are walked once, and each SQR is read a single time and split into identifiers by
+// the SQR lexer, which are looked up among the tables and fields of all changes. Files are
+// scanned by a pool of workers goroutines.
+
+var workers int = runtime.NumCPU() // Goroutines scanning SQRs
+
+// Tables and fields of the changes searched for in SQRs
+func sqrtargets(chgs []Change) []target {
+	var tgts []target
+	for i := range chgs {
+		if tbl, fld, ok := chgs[i].sqrtarget(); ok {
+			tgts = append(tgts, target{&chgs[i], tbl, fld})
+		}
+	}
+	return tgts
+}
+
+// Scanner of the custom SQRs in the SQR tree
+type sqrscanner struct{}
+
+func (sqrscanner) Name() string {
+	return "sqr"
+}
+
+// Scan the SQR tree once for all changes
+func (sqrscanner) Scan(db *sql.DB, chgs []Change) ([]Finding, error) {
+	tgts := sqrtargets(chgs)
+	if len(tgts) == 0 {
+		return nil, nil
+	}
+
+	x := newtgtindex(tgts, func(s string) []byte { return bytes.ToUpper([]byte(s)) })
+	ids := x.ids(false)
+
+	// The walk feeds a bounded pool of workers; their findings go over a channel to a single collector
+	type sqrfile struct {
+		n  int
+		fp string
+	}
+	type sqrfindings struct {
+		n int
+		p *sqrparse
+	}
+	files := make(chan sqrfile)
+	found := make(chan sqrfindings)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range files {
+				p, err := srchsqr(f.fp, tgts, x, ids)
+				if err != nil {
+					fmt.Println(err)
+				}
+				found <- sqrfindings{f.n, p}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	done := make(chan bool)
+	byfile := map[int]*sqrparse{}
+	go func() {
+		for f := range found {
+			byfile[f.n] = f.p
+		}
+		done <- true
+	}()
+
+	var paths []string
+	err := walksqrs(func(fp string, fi os.FileInfo) error {
+		files <- sqrfile{len(paths), fp}
+		paths = append(paths, fp)
+		return nil
+	})
+	close(files)
+	<-done
+	if err != nil {
+		return nil, err
+	}
+
+	// Report in walk order, each SQC finding followed by the SQRs including the SQC
+	g := newincgraph(paths)
+	for i, fp := range paths {
+		g.add(fp, byfile[i].incs)
+	}
+	reach, analyzed := reachable(g, paths, byfile)
+	var fs []Finding
+	for i, fp := range paths {
+		p := byfile[i]
+		for j, f := range p.fs {
+			if proc := p.procs[j]; proc != "" {
+				f.Location += " in procedure " + proc
+				if cp := filepath.Clean(fp); analyzed[cp] && !reach[cp][proc] {
+					f.Location += ", unreachable"
+				}
+			}
+			fs = append(fs, f)
+			for _, root := range g.roots(fp) {
+				r := f
+				r.Key = root
+				r.Location = "via " + fp + " " + f.Location
+				fs = append(fs, r)
+			}
+		}
+	}
+	return fs, prtfindings("Custom SQRs referencing the changes", chgs, fs)
+}
+
+// Findings, #include files and procedures of one SQR
+type sqrparse struct {
+	fs    []Finding
+	procs []string            // Procedure enclosing each finding, "" outside procedures
+	incs  []string            // Files named in #include directives
+	calls map[string][]string // Procedures called with do from each procedure, "" for the program sections
+	entry bool                // Has a begin-program or begin-report section
+}
+
+// Scan one SQR for all changes, and list its #include files and procedure calls. A table is found
+// when it is an identifier in the SQL block or on the line; a field change also needs the field in
+// the same block or line.
+func srchsqr(fp string, tgts []target, x *tgtindex, ids map[string]int) (*sqrparse, error) {
+	p := &sqrparse{calls: map[string][]string{}}
+	file, err := os.Open(fp)
+	if err != nil {
+		return p, err
+	}
+	defer file.Close()
+
+	type sqrhit struct {
+		n, t int // Line number and target
+		proc string
+	}
+	var hits []sqrhit
+	var proc string
+	texts := map[int]string{}
+	found := map[int]int{} // First line of each pattern in the block or line
+	flush := func() {
+		set := map[int]bool{}
+		for id := range found {
+			set[id] = true
+		}
+		for _, i := range x.hits(set) {
+			n := found[x.recpat[i]]
+			if c := x.colpat[i]; c >= 0 {
+				n = found[c]
+			}
+			hits = append(hits, sqrhit{n, i, proc})
+		}
+		found = map[int]int{}
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+
+	lineNumber := 0
+	insql := false
+	for scanner.Scan() {
+		lineNumber += 1
+		line := scanner.Bytes()
+		if inc, ok := sqrinclude(line); ok {
+			p.incs = append(p.incs, inc)
+			continue
+		}
+		switch cmd, arg := sqrcmd(line); cmd {
+		case "BEGIN-SELECT", "BEGIN-SQL":
+			flush()
+			insql = true
+			continue
+		case "END-SELECT", "END-SQL":
+			flush()
+			insql = false
+			continue
+		case "BEGIN-PROGRAM", "BEGIN-REPORT":
+			p.entry = true
+		case "BEGIN-PROCEDURE":
+			proc = arg
+		case "END-PROCEDURE":
+			proc = ""
+		case "DO":
+			p.calls[proc] = append(p.calls[proc], arg)
+		}
+		for _, tok := range sqrtokens(line, insql) {
+			if id, ok := ids[string(tok)]; ok {
+				if _, seen := found[id]; !seen {
+					found[id] = lineNumber
+					texts[lineNumber] = string(bytes.TrimSpace(line))
+				}
+			}
+		}
+		if !insql {
+			flush()
+		}
+	}
+	flush()
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].n != hits[j].n {
+			return hits[i].n < hits[j].n
+		}
+		return hits[i].t < hits[j].t
+	})
+	for _, h := range hits {
+		p.fs = append(p.fs, Finding{Change: tgts[h.t].c, Kind: kindsqr, Key: fp, Location: "line: " + strconv.Itoa(h.n), Snippet: texts[h.n]})
+		p.procs = append(p.procs, h.proc)
+	}
+	return p, scanner.Err()
+}
+
+// Procedures reachable from the program sections, by cleaned file path. Each top-level SQR is a program made of
+// the SQR and the files it includes; a procedure is reachable when do calls lead to it from the
+// sections of any program including its file. Files of programs without a begin-program or
+// begin-report section are not analyzed.
+func reachable(g *incgraph, paths []string, byfile map[int]*sqrparse) (reach map[string]map[string]bool, analyzed map[string]bool) {
+	parsed := map[string]*sqrparse{}
+	for i, fp := range paths {
+		parsed[filepath.Clean(fp)] = byfile[i]
+	}
+
+	reach, analyzed = map[string]map[string]bool{}, map[string]bool{}
+	for _, fp := range paths {
+		root := filepath.Clean(fp)
+		if len(g.parents[root]) > 0 {
+			continue
+		}
+		files := g.closure(root)
+		entry := false
+		calls := map[string][]string{}
+		for _, f := range files {
+			entry = entry || parsed[f].entry
+			for proc, c := range parsed[f].calls {
+				calls[proc] = append(calls[proc], c...)
+			}
+		}
+		if !entry {
+			continue
+		}
+
+		// Procedures called from the program sections, directly or through other procedures
+		seen := map[string]bool{}
+		queue := append([]string(nil), calls[""]...)
+		for len(queue) > 0 {
+			proc := queue[0]
+			queue = queue[1:]
+			if seen[proc] {
+				continue
+			}
+			seen[proc] = true
+			queue = append(queue, calls[proc]...)
+		}
+
+		for _, f := range files {
+			analyzed[f] = true
+			if reach[f] == nil {
+				reach[f] = map[string]bool{}
+			}
+			for proc := range seen {
+				reach[f][proc] = true
+			}
+		}
+	}
+	return reach, analyzed
+}
@@ -0,0 +1,115 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SQC files are reached through #include directives. As with the SQR -I option, an include file
+// is looked for in the directory of the including file, then in the sqrinc directories and
+// finally in the SQR directories. The include graph covers the scanned files only; a finding in an
+// included file is also reported against every top-level SQR that includes it, directly or
+// through other SQCs.
+
+var sqrinc []string // Directories searched for #include files, as with the SQR -I option
+
+// Name of the file included by an #include directive
+func sqrinclude(line []byte) (string, bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) < 9 || !bytes.EqualFold(line[:8], []byte("#include")) || !strings.ContainsRune(" \t'\"", rune(line[8])) {
+		return "", false
+	}
+	name := string(bytes.TrimSpace(line[8:]))
+	if name != "" && (name[0] == '\'' || name[0] == '"') {
+		if i := strings.IndexByte(name[1:], name[0]); i >= 0 {
+			name = name[1 : i+1]
+		}
+	} else if f := strings.Fields(name); len(f) > 0 {
+		name = f[0]
+	}
+	return name, name != "" && name[0] != '!'
+}
+
+// Include graph of the scanned SQRs
+type incgraph struct {
+	scanned  map[string]bool
+	parents  map[string][]string // Files including each file
+	children map[string][]string // Files included by each file
+}
+
+func newincgraph(paths []string) *incgraph {
+	g := &incgraph{scanned: map[string]bool{}, parents: map[string][]string{}, children: map[string][]string{}}
+	for _, fp := range paths {
+		g.scanned[filepath.Clean(fp)] = true
+	}
+	return g
+}
+
+// Add the #include directives of a scanned file
+func (g *incgraph) add(fp string, incs []string) {
+	for _, inc := range incs {
+		if p := g.resolve(fp, inc); p != "" {
+			g.parents[p] = append(g.parents[p], filepath.Clean(fp))
+			g.children[filepath.Clean(fp)] = append(g.children[filepath.Clean(fp)], p)
+		}
+	}
+}
+
+// Scanned file included from fp, "" if it is not among the scanned files
+func (g *incgraph) resolve(fp, name string) string {
+	dirs := []string{""}
+	if !filepath.IsAbs(name) {
+		dirs = append([]string{filepath.Dir(fp)}, sqrinc...)
+		dirs = append(dirs, sqrdirs...)
+	}
+	for _, d := range dirs {
+		for _, n := range []string{name, strings.ToLower(name)} {
+			if p := filepath.Clean(filepath.Join(d, n)); g.scanned[p] {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// Top-level files including fp, directly or transitively; none when fp is not included
+func (g *incgraph) roots(fp string) []string {
+	var roots []string
+	seen := map[string]bool{filepath.Clean(fp): true}
+	var walk func(string)
+	walk = func(f string) {
+		for _, p := range g.parents[f] {
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+			if len(g.parents[p]) == 0 {
+				roots = append(roots, p)
+			}
+			walk(p)
+		}
+	}
+	walk(filepath.Clean(fp))
+	sort.Strings(roots)
+	return roots
+}
+
+// Files making up the program of a top-level file: the file and all files it includes
+func (g *incgraph) closure(root string) []string {
+	files := []string{root}
+	seen := map[string]bool{root: true}
+	for i := 0; i < len(files); i++ {
+		for _, c := range g.children[files[i]] {
+			if !seen[c] {
+				seen[c] = true
+				files = append(files, c)
+			}
+		}
+	}
+	return files
+}
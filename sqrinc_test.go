@@ -0,0 +1,55 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSqrinclude(t *testing.T) {
+	tests := []struct {
+		line, name string
+		ok         bool
+	}{
+		{"#include 'setenv.sqc'   ! Set environment", "setenv.sqc", true},
+		{`  #INCLUDE "stdapi.sqc"`, "stdapi.sqc", true},
+		{"#include curdttim.sqc", "curdttim.sqc", true},
+		{"#include ! nothing", "!", false},
+		{"#includes 'x.sqc'", "", false},
+		{"! #include 'x.sqc'", "", false},
+	}
+	for _, tt := range tests {
+		if name, ok := sqrinclude([]byte(tt.line)); name != tt.name || ok != tt.ok {
+			t.Errorf("sqrinclude(%q) = %q, %v, want %q, %v", tt.line, name, ok, tt.name, tt.ok)
+		}
+	}
+}
+
+func TestIncgraph(t *testing.T) {
+	defer func(i, d []string) { sqrinc, sqrdirs = i, d }(sqrinc, sqrdirs)
+	sqrinc, sqrdirs = []string{"/inc"}, []string{"/sqr"}
+
+	g := newincgraph([]string{"/sqr/a.sqr", "/sqr/b.sqr", "/sqr/local.sqc", "/inc/std.sqc", "/inc/dt.sqc"})
+	g.add("/sqr/a.sqr", []string{"local.sqc", "STD.SQC"})
+	g.add("/sqr/b.sqr", []string{"std.sqc", "missing.sqc"})
+	g.add("/inc/std.sqc", []string{"dt.sqc"})
+
+	tests := []struct {
+		fp   string
+		want []string
+	}{
+		{"/inc/dt.sqc", []string{"/sqr/a.sqr", "/sqr/b.sqr"}},
+		{"/sqr/local.sqc", []string{"/sqr/a.sqr"}},
+		{"/sqr/a.sqr", nil},
+	}
+	for _, tt := range tests {
+		if got := g.roots(tt.fp); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("roots(%s) = %q, want %q", tt.fp, got, tt.want)
+		}
+	}
+	if got, want := g.closure("/sqr/b.sqr"), []string{"/sqr/b.sqr", "/inc/std.sqc", "/inc/dt.sqc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("closure = %q, want %q", got, want)
+	}
+}
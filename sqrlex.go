@@ -0,0 +1,75 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"bytes"
+)
+
+// SQR lexer. Only identifiers are matched against the changed tables and fields: text after a !
+// comment, 'quoted' literals and $string, #numeric and &column variables are skipped, and an
+// identifier ends at any character that cannot be part of a name, so PS_JOB does not match
+// PS_JOB_DATA. Lines between begin-select or begin-sql and end-select or end-sql form one SQL
+// block; outside a block each line stands alone. Commands are read from the start of a line.
+
+// Character that can be part of an SQR or SQL identifier. SQR command names contain hyphens,
+// SQL names do not.
+func identchar(c byte, insql bool) bool {
+	switch {
+	case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '_', c == '$', c == '#':
+		return true
+	case c == '-':
+		return !insql
+	}
+	return false
+}
+
+// Upper-cased identifiers on one SQR line
+func sqrtokens(line []byte, insql bool) [][]byte {
+	var toks [][]byte
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == '!' && (i+1 == len(line) || line[i+1] != '='):
+			return toks // comment to the end of the line
+		case c == '\'':
+			// string literal; '' is a quote inside it
+			for i++; i < len(line); i++ {
+				if line[i] == '\'' {
+					if i+1 < len(line) && line[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			i++
+		case c == '$' || c == '#' || c == '&' || c == '@':
+			// variable, including the dotted &A.EMPLID column variables
+			for i++; i < len(line) && (identchar(line[i], false) || line[i] == '.'); i++ {
+			}
+		case identchar(c, insql) && c != '-':
+			j := i
+			for i++; i < len(line) && identchar(line[i], insql); i++ {
+			}
+			toks = append(toks, bytes.ToUpper(line[j:i]))
+		default:
+			i++
+		}
+	}
+	return toks
+}
+
+// SQR command starting a line and its first argument, such as BEGIN-PROCEDURE and the procedure
+// name or DO and the procedure called
+func sqrcmd(line []byte) (cmd, arg string) {
+	toks := sqrtokens(line, false)
+	switch len(toks) {
+	case 0:
+		return "", ""
+	case 1:
+		return string(toks[0]), ""
+	}
+	return string(toks[0]), string(toks[1])
+}
@@ -0,0 +1,55 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Tokens joined by spaces, for comparing
+func joined(toks [][]byte) string {
+	s := make([]string, len(toks))
+	for i, t := range toks {
+		s[i] = string(t)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestSqrtokens(t *testing.T) {
+	tests := []struct {
+		line  string
+		insql bool
+		want  string
+	}{
+		{"from PS_JOB_DATA j", true, "FROM PS_JOB_DATA J"},
+		{"from ps_job, ps_job_data", true, "FROM PS_JOB PS_JOB_DATA"},
+		{"let $a = 'PS_JOB' ! PS_JOB in a comment", false, "LET"},
+		{"where a.emplid != $emplid", true, "WHERE A EMPLID"},
+		{"display 'it''s PS_JOB'", false, "DISPLAY"},
+		{"&A.EMPLID, #count, @x", true, ""},
+		{"do get-job-data", false, "DO GET-JOB-DATA"},
+		{"a.x-b.y", true, "A X B Y"},
+	}
+	for _, tt := range tests {
+		if got := joined(sqrtokens([]byte(tt.line), tt.insql)); got != tt.want {
+			t.Errorf("sqrtokens(%q, %v) = %q, want %q", tt.line, tt.insql, got, tt.want)
+		}
+	}
+}
+
+func TestSqrcmd(t *testing.T) {
+	tests := []struct {
+		line, cmd, arg string
+	}{
+		{"begin-procedure Get-Job", "BEGIN-PROCEDURE", "GET-JOB"},
+		{"  end-select", "END-SELECT", ""},
+		{"! comment", "", ""},
+	}
+	for _, tt := range tests {
+		if cmd, arg := sqrcmd([]byte(tt.line)); cmd != tt.cmd || arg != tt.arg {
+			t.Errorf("sqrcmd(%q) = %q, %q, want %q, %q", tt.line, cmd, arg, tt.cmd, tt.arg)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The SQR directories are walked in order. A pattern without a slash matches file and directory
+// names; a pattern with a slash matches the path relative to the SQR directory, and a trailing
+// slash limits it to directories, so "archive/" skips every archive folder and "old/*.sqr" the
+// SQRs directly under old. Each SQR directory may hold a .tekopiaignore file of further exclude
+// patterns, one per line, with # comments. Symbolic links are skipped unless followlinks is set.
+// Each file is found once, by its real path, even where SQR directories overlap.
+
+const ignorefile = ".tekopiaignore"
+
+var followlinks bool // Follow symbolic links to files and directories in the SQR directories
+
+// Check that the include and exclude patterns are well formed
+func chkpatterns(pats []string) error {
+	for _, p := range pats {
+		if _, err := path.Match(strings.TrimSuffix(p, "/"), ""); err != nil {
+			return fmt.Errorf("malformed pattern %q: %v", p, err)
+		}
+	}
+	return nil
+}
+
+// Report whether a file or directory matches any of the patterns
+func matchglob(pats []string, rel string, dir bool) bool {
+	for _, p := range pats {
+		if strings.HasSuffix(p, "/") {
+			if !dir {
+				continue
+			}
+			p = strings.TrimSuffix(p, "/")
+		}
+		name := rel
+		if strings.Contains(p, "/") {
+			p = strings.TrimPrefix(p, "/")
+		} else {
+			name = path.Base(rel)
+		}
+		if matched, _ := path.Match(p, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Exclude patterns of the .tekopiaignore file in an SQR directory
+func readignore(dir string) ([]string, error) {
+	file, err := os.Open(filepath.Join(dir, ignorefile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var pats []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if l := strings.TrimSpace(scanner.Text()); l != "" && !strings.HasPrefix(l, "#") {
+			pats = append(pats, l)
+		}
+	}
+	if err = scanner.Err(); err == nil {
+		err = chkpatterns(pats)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Join(dir, ignorefile), err)
+	}
+	return pats, nil
+}
+
+// Call fn for each SQR in the SQR directories, in walk order
+func walksqrs(fn func(fp string, fi os.FileInfo) error) error {
+	return walkfiles(sqrdirs, sqrpats, fn)
+}
+
+// Call fn for each file matching pats in the directories, in walk order
+func walkfiles(roots, pats []string, fn func(fp string, fi os.FileInfo) error) error {
+	seen := map[string]bool{}
+	for _, root := range roots {
+		ign, err := readignore(root)
+		if err != nil {
+			return err
+		}
+		excl := append(append([]string(nil), sqrexcl...), ign...)
+		if real, err := filepath.EvalSymlinks(root); err == nil {
+			if seen[real] {
+				continue // already walked under an earlier root
+			}
+			seen[real] = true
+		}
+		if err = walkdir(root, "", pats, excl, seen, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkdir(dir, rel string, pats, excl []string, seen map[string]bool, fn func(fp string, fi os.FileInfo) error) error {
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Println(err) // can't walk here,
+		return nil       // but continue walking elsewhere
+	}
+	for _, de := range des {
+		fp := filepath.Join(dir, de.Name())
+		r := path.Join(rel, de.Name())
+		var fi os.FileInfo
+		if de.Type()&os.ModeSymlink != 0 {
+			if !followlinks {
+				continue
+			}
+			if fi, err = os.Stat(fp); err != nil {
+				fmt.Println(err) // dangling link
+				continue
+			}
+		} else if fi, err = de.Info(); err != nil {
+			fmt.Println(err) // removed since the directory was read
+			continue
+		}
+		if matchglob(excl, r, fi.IsDir()) {
+			continue
+		}
+		if fi.IsDir() {
+			real, err := filepath.EvalSymlinks(fp)
+			if err != nil || seen[real] {
+				continue // already walked through another link
+			}
+			seen[real] = true
+			if err = walkdir(fp, r, pats, excl, seen, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if matchglob(pats, r, false) {
+			real, err := filepath.EvalSymlinks(fp)
+			if err != nil || seen[real] {
+				continue // already found through another root or link
+			}
+			seen[real] = true
+			if err = fn(fp, fi); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Oracle PeopleSoft Upgrade Customization Impact Analysis Report.
+// Copyright © 2015 Annet Libeau. Sun Day Consulting, Inc.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatchglob(t *testing.T) {
+	tests := []struct {
+		pats []string
+		rel  string
+		dir  bool
+		want bool
+	}{
+		{[]string{"*.sq?"}, "hr/job.sqr", false, true},
+		{[]string{"*.sq?"}, "hr/job.sqrx", false, false},
+		{[]string{"archive/"}, "old/archive", true, true},
+		{[]string{"archive/"}, "old/archive", false, false},
+		{[]string{"old/*.sqr"}, "old/job.sqr", false, true},
+		{[]string{"old/*.sqr"}, "new/old/job.sqr", false, false},
+		{[]string{"/old/*.sqr"}, "old/job.sqr", false, true},
+		{[]string{"*.bak", "archive/"}, "job.bak", false, true},
+		{nil, "job.sqr", false, false},
+	}
+	for _, tt := range tests {
+		if got := matchglob(tt.pats, tt.rel, tt.dir); got != tt.want {
+			t.Errorf("matchglob(%q, %q, %v) = %v, want %v", tt.pats, tt.rel, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestChkpatterns(t *testing.T) {
+	if err := chkpatterns([]string{"*.sqr", "archive/"}); err != nil {
+		t.Error(err)
+	}
+	if err := chkpatterns([]string{"[a"}); err == nil {
+		t.Error("malformed pattern accepted")
+	}
+}
+
+func TestWalkfilesOverlap(t *testing.T) {
+	x := t.TempDir()
+	for _, f := range []string{"a.sqr", "sqr/b.sqr", "sqr/c.sqc"} {
+		fp := filepath.Join(x, f)
+		if err := os.MkdirAll(filepath.Dir(fp), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fp, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sqr := filepath.Join(x, "sqr")
+	tests := []struct {
+		roots []string
+		want  []string
+	}{
+		{[]string{x, sqr}, []string{"a.sqr", "sqr/b.sqr", "sqr/c.sqc"}},
+		{[]string{sqr, x}, []string{"sqr/b.sqr", "sqr/c.sqc", "a.sqr"}},
+		{[]string{sqr, sqr + "/"}, []string{"sqr/b.sqr", "sqr/c.sqc"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		err := walkfiles(tt.roots, []string{"*.sq?"}, func(fp string, fi os.FileInfo) error {
+			rel, _ := filepath.Rel(x, fp)
+			got = append(got, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("walkfiles(%q) = %q, want %q", tt.roots, got, tt.want)
+		}
+	}
+}